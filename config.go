@@ -1,5 +1,129 @@
 package main
 
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nlopes/slack"
+	legacyslack "github.com/timdurward/slack"
+)
+
+const defaultAPIURL = "https://slack.com/api/"
+
+// Token types, told apart by the prefix of the token
+const (
+	tokenTypeUser      = "user"
+	tokenTypeBot       = "bot"
+	tokenTypeWorkspace = "workspace"
+	tokenTypeApp       = "app"
+	tokenTypeUnknown   = "unknown"
+)
+
+var tokenTypePrefixes = map[string]string{
+	"xoxp-": tokenTypeUser,
+	"xoxb-": tokenTypeBot,
+	"xoxa-": tokenTypeWorkspace,
+	"xapp-": tokenTypeApp,
+}
+
 type Config struct {
-	APIToken string
+	APIToken   string
+	APIURL     string
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Parallelism bounds the concurrent lookups of a single resource, they share the same rate limited Client
+	Parallelism int
+
+	// Users that are never kicked out of a conversation, on top of the protected_members of each resource
+	NeverKickBots   bool
+	NeverKickAdmins bool
+	// MaxRemovals is the default max_removals of slack_conversation_members, -1 means no limit
+	MaxRemovals int
+
+	// UserCache caches the users of the workspace for UserCacheTTL, instead of looking them up one by one
+	UserCache    bool
+	UserCacheTTL time.Duration
+
+	// Owner of APIToken, as reported by auth.test at configure time
+	TeamID   string
+	TeamName string
+	TeamURL  string
+	UserID   string
+	UserName string
+	// BotID is only set for bot tokens
+	BotID     string
+	TokenType string
+
+	// Client is shared by every resource and data source of the provider
+	Client *slack.Client
+	// Users looks users up through Client, sharing its cache between every resource and data source
+	Users *userDirectory
+	// LegacyClient is the timdurward/slack fork, still needed for the channels.* methods it adds (i.e. channels.delete)
+	LegacyClient *legacyslack.Client
+}
+
+// loadClients builds the long-lived Slack clients, both going through the same rate limit aware transport
+func (c *Config) loadClients() {
+	apiURL := c.APIURL
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	// Both libraries build method URLs by plain concatenation (i.e. APIURL + "conversations.info")
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+
+	httpClient := &http.Client{
+		Transport: newBaseURLTransport(newRateLimitedTransport(http.DefaultTransport, c.MaxRetries, c.MinBackoff, c.MaxBackoff), apiURL),
+	}
+
+	c.Client = slack.New(c.APIToken, slack.OptionHTTPClient(httpClient))
+	c.Users = newUserDirectory(c.Client, c.UserCache, c.UserCacheTTL)
+	c.LegacyClient = legacyslack.New(c.APIToken, legacyslack.OptionHTTPClient(httpClient))
+}
+
+// authenticate checks APIToken with auth.test and records its owner, so that a revoked or unusable token
+// fails at configure time instead of deep inside some resource
+func (c *Config) authenticate() error {
+	c.TokenType = getTokenType(c.APIToken)
+	switch c.TokenType {
+	case tokenTypeApp:
+		return fmt.Errorf("api_token is an app-level token (xapp-), which can't call the Web API methods the provider uses: use a user (xoxp-) or bot (xoxb-) token")
+	case tokenTypeUnknown:
+		log.Printf("[WARN] api_token does not start with a known Slack token prefix (xoxp-, xoxb-, xoxa-)")
+	}
+
+	auth, err := c.Client.AuthTest()
+	if err != nil {
+		return slackErrorf("checking api_token with auth.test", err)
+	}
+	c.TeamID, c.TeamName, c.TeamURL = auth.TeamID, auth.Team, auth.URL
+	c.UserID, c.UserName = auth.UserID, auth.User
+
+	// auth.test only reports the bot ID of bot tokens in recent API versions, the bot user profile always has it
+	if c.TokenType == tokenTypeBot {
+		u, err := c.Client.GetUserInfo(c.UserID)
+		if err != nil {
+			log.Printf("[WARN] Could not get the bot ID of %s, users.info failed: %s", c.UserID, err)
+		} else {
+			c.BotID = u.Profile.BotID
+		}
+	}
+	log.Printf("[INFO] Slack API token is a %s token of %s (%s) in team %s (%s)", c.TokenType, c.UserName, c.UserID, c.TeamName, c.TeamID)
+	return nil
+}
+
+// getTokenType returns the type of a Slack token from its prefix
+func getTokenType(token string) string {
+	for prefix, tokenType := range tokenTypePrefixes {
+		if strings.HasPrefix(token, prefix) {
+			return tokenType
+		}
+	}
+	return tokenTypeUnknown
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+func dataSourceSlackAuth() *schema.Resource {
+	return &schema.Resource{
+		Read: dataSourceSlackAuthRead,
+
+		Schema: map[string]*schema.Schema{
+			"team_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"team_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"team_url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"user_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the token owner, the bot user of bot tokens",
+			},
+			"user_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"bot_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "ID of the bot (i.e. BXXXXXXXX), only set for bot tokens",
+			},
+			"token_type": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Type of api_token, told apart by its prefix: user, bot, workspace or unknown",
+			},
+		},
+	}
+}
+
+// The token was already checked with auth.test when configuring the provider
+func dataSourceSlackAuthRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*Config)
+
+	d.SetId(config.TeamID + ":" + config.UserID)
+	d.Set("team_id", config.TeamID)
+	d.Set("team_name", config.TeamName)
+	d.Set("team_url", config.TeamURL)
+	d.Set("user_id", config.UserID)
+	d.Set("user_name", config.UserName)
+	d.Set("bot_id", config.BotID)
+	d.Set("token_type", config.TokenType)
+	return nil
+}
@@ -3,60 +3,182 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
-	"github.com/timdurward/slack"
+	"github.com/nlopes/slack"
 )
 
+// Attributes of slack_user allowing to look up a user, exactly one of them must be set
+var dataSourceSlackUserLookupKeys = []string{"email", "id", "name", "display_name"}
+
 func dataSourceSlackUser() *schema.Resource {
 	return &schema.Resource{
 		Read: dataSourceSlackUserRead,
 
 		Schema: map[string]*schema.Schema{
+			"id": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				Description:   "ID of the user to look up (i.e. UXXXXXXXX)",
+				ConflictsWith: []string{"email", "name", "display_name"},
+			},
+			"email": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				Description:   "Email of the user to look up, resolved through users.lookupByEmail",
+				ConflictsWith: []string{"id", "name", "display_name"},
+			},
 			"name": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				Description:   "Username of the user to look up",
+				ConflictsWith: []string{"id", "email", "display_name"},
+			},
+			"display_name": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				Computed:      true,
+				Description:   "Display name of the user to look up, it must match a single user",
+				ConflictsWith: []string{"id", "email", "name"},
 			},
 			"real_name": {
 				Type:     schema.TypeString,
-				Optional: true,
+				Computed: true,
 			},
-			"display_name": {
+			"team_id": {
 				Type:     schema.TypeString,
-				Optional: true,
+				Computed: true,
 			},
-			"email": {
+			"title": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"tz": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"is_admin": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"is_owner": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"is_bot": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"is_restricted": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"is_ultra_restricted": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"deleted": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"image_24": {
 				Type:     schema.TypeString,
-				Required: true,
+				Computed: true,
+			},
+			"image_72": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"image_192": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"image_original": {
+				Type:     schema.TypeString,
+				Computed: true,
 			},
 		},
 	}
 }
 
 func dataSourceSlackUserRead(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	users := meta.(*Config).Users
 
-	email := d.Get("email").(string)
-	log.Printf("[INFO] Reading Slack user '%s'", email)
-	// user, err := api.GetUserByEmail(email)
-	// if err != nil {
-	// 	return fmt.Errorf("Invalid user with email '%s'", email)
-	// }
+	var lookupKey, lookupValue string
+	for _, k := range dataSourceSlackUserLookupKeys {
+		if v, ok := d.GetOk(k); ok {
+			lookupKey, lookupValue = k, v.(string)
+			break
+		}
+	}
+	log.Printf("[INFO] Reading Slack user by %s '%s'", lookupKey, lookupValue)
 
-	users, err := api.GetUsers()
+	var user *slack.User
+	var err error
+	switch lookupKey {
+	case "email":
+		user, err = users.GetUserByEmail(lookupValue)
+	case "id":
+		user, err = users.GetUserInfo(lookupValue)
+	case "name":
+		user, err = findUser(users, lookupKey, lookupValue, func(u slack.User) bool { return u.Name == lookupValue })
+	case "display_name":
+		user, err = findUser(users, lookupKey, lookupValue, func(u slack.User) bool { return u.Profile.DisplayName == lookupValue })
+	default:
+		return fmt.Errorf("exactly one of %s must be set to look up a Slack user", strings.Join(dataSourceSlackUserLookupKeys, ", "))
+	}
 	if err != nil {
-		return err
-	}
-	for _, user := range users {
-		if user.Profile.Email == email {
-			log.Printf("[DEBUG] Slack user: %v", user)
-			d.SetId(user.ID)
-			d.Set("name", user.Name)
-			d.Set("real_name", user.RealName)
-			d.Set("email", user.Profile.Email)
-			return nil
-		}
+		return slackErrorf(fmt.Sprintf("could not find Slack user with %s '%s'", lookupKey, lookupValue), err)
 	}
+	log.Printf("[DEBUG] Slack user: %v", user)
 
-	return fmt.Errorf("Invalid user with email '%s'", email)
+	d.SetId(user.ID)
+	d.Set("email", user.Profile.Email)
+	d.Set("name", user.Name)
+	d.Set("display_name", user.Profile.DisplayName)
+	d.Set("real_name", user.RealName)
+	d.Set("team_id", user.TeamID)
+	d.Set("title", user.Profile.Title)
+	d.Set("tz", user.TZ)
+	d.Set("is_admin", user.IsAdmin)
+	d.Set("is_owner", user.IsOwner)
+	d.Set("is_bot", user.IsBot)
+	d.Set("is_restricted", user.IsRestricted)
+	d.Set("is_ultra_restricted", user.IsUltraRestricted)
+	d.Set("deleted", user.Deleted)
+	d.Set("image_24", user.Profile.Image24)
+	d.Set("image_72", user.Profile.Image72)
+	d.Set("image_192", user.Profile.Image192)
+	d.Set("image_original", user.Profile.ImageOriginal)
+	return nil
+}
+
+// Returns the single user of the workspace matching a predicate, users.list has no server side filtering
+func findUser(directory *userDirectory, key, value string, match func(u slack.User) bool) (*slack.User, error) {
+	users, err := directory.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]slack.User, 0)
+	for _, u := range users {
+		if match(u) {
+			matching = append(matching, u)
+		}
+	}
+	switch len(matching) {
+	case 0:
+		return nil, &slackError{Code: "user_not_found"}
+	case 1:
+		return &matching[0], nil
+	}
+	ids := make([]string, len(matching))
+	for i, u := range matching {
+		ids[i] = u.ID
+	}
+	return nil, fmt.Errorf("%d users have the %s '%s' (%s), use a more specific lookup", len(matching), key, value, strings.Join(ids, ", "))
 }
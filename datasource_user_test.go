@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+)
+
+func TestAccDataSourceSlackUser_lookups(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{
+		Name:        "alice",
+		RealName:    "Alice Liddell",
+		DisplayName: "ali",
+		Email:       "alice@example.com",
+		Title:       "Engineer",
+		TZ:          "Europe/Paris",
+		IsAdmin:     true,
+	})
+
+	for _, lookup := range []string{
+		`email = "alice@example.com"`,
+		fmt.Sprintf(`id = %q`, alice),
+		`name = "alice"`,
+		`display_name = "ali"`,
+	} {
+		resource.Test(t, resource.TestCase{
+			PreCheck:  func() { testAccPreCheck(t) },
+			Providers: testAccProviders,
+			Steps: []resource.TestStep{
+				{
+					Config: testAccDataSourceSlackUserConfig(lookup),
+					Check: resource.ComposeTestCheckFunc(
+						resource.TestCheckResourceAttr("data.slack_user.test", "id", alice),
+						resource.TestCheckResourceAttr("data.slack_user.test", "email", "alice@example.com"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "name", "alice"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "real_name", "Alice Liddell"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "title", "Engineer"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "tz", "Europe/Paris"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "is_admin", "true"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "is_bot", "false"),
+						resource.TestCheckResourceAttr("data.slack_user.test", "deleted", "false"),
+					),
+				},
+			},
+		})
+	}
+}
+
+func TestAccDataSourceSlackUser_ambiguousDisplayName(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	f.AddUser(&fakeUser{Name: "alice", DisplayName: "ali"})
+	f.AddUser(&fakeUser{Name: "alister", DisplayName: "ali"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDataSourceSlackUserConfig(`display_name = "ali"`),
+				ExpectError: regexp.MustCompile("2 users have the display_name 'ali'"),
+			},
+		},
+	})
+}
+
+func testAccDataSourceSlackUserConfig(lookup string) string {
+	return fmt.Sprintf(`
+data "slack_user" "test" {
+  %s
+}
+`, lookup)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Prefixes of the member expressions of slack_conversation_members
+const (
+	memberExpressionIDPrefix        = "id:"
+	memberExpressionEmailPrefix     = "email:"
+	memberExpressionNamePrefix      = "name:"
+	memberExpressionUserGroupPrefix = "usergroup:"
+)
+
+var memberExpressionPrefixes = []string{
+	memberExpressionIDPrefix,
+	memberExpressionEmailPrefix,
+	memberExpressionNamePrefix,
+	memberExpressionUserGroupPrefix,
+}
+
+// User IDs start with U, or W for Enterprise Grid users
+var userIDRegexp = regexp.MustCompile(`^[UW][A-Z0-9]{2,}$`)
+
+// Splits a member expression (i.e. "email:my@email.corp") into its prefix and value, checking the value's format
+func parseMemberExpression(expression string) (prefix, value string, err error) {
+	for _, p := range memberExpressionPrefixes {
+		if strings.HasPrefix(expression, p) {
+			prefix, value = p, strings.TrimPrefix(expression, p)
+			break
+		}
+	}
+	if prefix == "" {
+		return "", "", fmt.Errorf("member expression %q must start with one of %s", expression, strings.Join(memberExpressionPrefixes, ", "))
+	}
+	if value == "" {
+		return "", "", fmt.Errorf("member expression %q has an empty value", expression)
+	}
+
+	switch prefix {
+	case memberExpressionIDPrefix:
+		if !userIDRegexp.MatchString(value) {
+			return "", "", fmt.Errorf("member expression %q: %q is not a Slack user ID (i.e. U0123ABCD)", expression, value)
+		}
+	case memberExpressionEmailPrefix:
+		if a, err := mail.ParseAddress(value); err != nil || a.Address != value {
+			return "", "", fmt.Errorf("member expression %q: %q is not a valid email address", expression, value)
+		}
+	case memberExpressionNamePrefix, memberExpressionUserGroupPrefix:
+		if strings.HasPrefix(value, "@") || strings.ContainsAny(value, " \t\n") {
+			return "", "", fmt.Errorf("member expression %q: %q must be given without '@' nor whitespaces", expression, value)
+		}
+	}
+	return prefix, value, nil
+}
+
+func validateMemberExpression(v interface{}, k string) (ws []string, errors []error) {
+	if _, _, err := parseMemberExpression(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMemberExpression(t *testing.T) {
+	cases := []struct {
+		expression string
+		prefix     string
+		value      string
+		err        string
+	}{
+		{expression: "id:U0123ABCD", prefix: "id:", value: "U0123ABCD"},
+		{expression: "id:W0123ABCD", prefix: "id:", value: "W0123ABCD"},
+		{expression: "email:alice@example.com", prefix: "email:", value: "alice@example.com"},
+		{expression: "name:alice.liddell", prefix: "name:", value: "alice.liddell"},
+		{expression: "usergroup:devops", prefix: "usergroup:", value: "devops"},
+		{expression: "usergroup:S0123ABCD", prefix: "usergroup:", value: "S0123ABCD"},
+		{expression: "U0123ABCD", err: "must start with one of id:, email:, name:, usergroup:"},
+		{expression: "ID:U0123ABCD", err: "must start with one of"},
+		{expression: "id:", err: "has an empty value"},
+		{expression: "id:u0123abcd", err: "is not a Slack user ID"},
+		{expression: "id:C0123ABCD", err: "is not a Slack user ID"},
+		{expression: "id:foo-email:x", err: "is not a Slack user ID"},
+		{expression: "email:alice", err: "is not a valid email address"},
+		{expression: "email:Alice <alice@example.com>", err: "is not a valid email address"},
+		{expression: "name:@alice", err: "without '@'"},
+		{expression: "usergroup:dev ops", err: "without '@'"},
+	}
+
+	for _, tc := range cases {
+		prefix, value, err := parseMemberExpression(tc.expression)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%s: expected an error containing %q, got %v", tc.expression, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: err: %s", tc.expression, err)
+		}
+		if prefix != tc.prefix || value != tc.value {
+			t.Fatalf("%s: expected %q and %q, got %q and %q", tc.expression, tc.prefix, tc.value, prefix, value)
+		}
+	}
+}
+
+func TestValidateMemberExpression(t *testing.T) {
+	_, errors := validateMemberExpression("idU0123ABCD", "members.2")
+	if len(errors) != 1 || !strings.HasPrefix(errors[0].Error(), `"members.2": `) {
+		t.Fatalf("expected an error naming members.2, got %v", errors)
+	}
+	if _, errors := validateMemberExpression("id:U0123ABCD", "members.0"); len(errors) != 0 {
+		t.Fatalf("expected no error, got %v", errors)
+	}
+}
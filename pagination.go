@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+
+	"github.com/nlopes/slack"
+)
+
+// Page sizes requested from Slack's cursor paginated methods, Slack may still return smaller pages
+const (
+	// conversations.* accept up to 1000 items per page
+	conversationsPageLimit = 1000
+	// users.list recommends no more than 200 users per page
+	usersPageLimit = 200
+)
+
+// Returns the IDs of every member of a conversation, going through all the pages of conversations.members
+func getConversationMembers(api *slack.Client, channelID string) ([]string, error) {
+	members := make([]string, 0)
+	params := &slack.GetUsersInConversationParameters{
+		ChannelID: channelID,
+		Limit:     conversationsPageLimit,
+	}
+	for {
+		page, nextCursor, err := api.GetUsersInConversation(params)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+		if nextCursor == "" {
+			return members, nil
+		}
+		params.Cursor = nextCursor
+	}
+}
+
+// Returns every user of the workspace, going through all the pages of users.list
+func getAllUsers(api *slack.Client) ([]slack.User, error) {
+	users := make([]slack.User, 0)
+	// Unlike GetUsers, stops at the first error instead of retrying the same page forever
+	p := api.GetUsersPaginated(slack.GetUsersOptionLimit(usersPageLimit))
+	for {
+		var err error
+		p, err = p.Next(context.Background())
+		if p.Done(err) {
+			return users, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, p.Users...)
+	}
+}
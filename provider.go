@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"net/url"
+	"time"
+
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
 )
 
@@ -14,18 +19,139 @@ func Provider() terraform.ResourceProvider {
 				DefaultFunc: schema.EnvDefaultFunc("SLACK_API_TOKEN", nil),
 				Description: "Slack Authentication Token for api.slack.com.",
 			},
+			"api_url": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("SLACK_API_URL", defaultAPIURL),
+				Description:  "Base URL of the Slack Web API, can be pointed at a mock server for testing purposes.",
+				ValidateFunc: validateAPIURL,
+			},
+			"max_retries": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				Description:  "Maximum number of times a rate limited (HTTP 429) Slack API call is retried.",
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"min_backoff": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("SLACK_MIN_BACKOFF", "1s"),
+				Description:  "Minimum delay between two retries when Slack does not send a Retry-After header (i.e. '500ms', '1s').",
+				ValidateFunc: validateDuration,
+			},
+			"max_backoff": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				DefaultFunc:  schema.EnvDefaultFunc("SLACK_MAX_BACKOFF", "30s"),
+				Description:  "Maximum delay between two retries when Slack does not send a Retry-After header.",
+				ValidateFunc: validateDuration,
+			},
+			"parallelism": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      4,
+				Description:  "Maximum number of concurrent Slack API calls a resource makes to resolve its members, all of them being subject to the same rate limit retries.",
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"never_kick_bots": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If set to true, bot users are never kicked out of a conversation, i.e. by an authoritative slack_conversation_members.",
+			},
+			"never_kick_admins": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "If set to true, workspace admins and owners are never kicked out of a conversation, i.e. by an authoritative slack_conversation_members.",
+			},
+			"user_cache": &schema.Schema{
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "If set to true, the users of the workspace are listed once (users.list) and cached for every resource and data source, instead of being looked up one by one.",
+			},
+			"user_cache_ttl": &schema.Schema{
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "5m",
+				Description:  "How long the cached users are used before being listed again (i.e. '30s', '5m').",
+				ValidateFunc: validateDuration,
+			},
+			"max_removals": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      -1,
+				Description:  "Default maximum number of users a slack_conversation_members apply may kick out of its conversation, -1 means no limit.",
+				ValidateFunc: validation.IntAtLeast(-1),
+			},
+		},
+		DataSourcesMap: map[string]*schema.Resource{
+			"slack_auth": dataSourceSlackAuth(),
+			"slack_user": dataSourceSlackUser(),
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"slack_channel":              resourceChannel(),
 			"slack_conversation_members": resourceConversationMembers(),
+			"slack_conversation_member":  resourceConversationMember(),
 		},
 		ConfigureFunc: configureProvider,
 	}
 }
 
 func configureProvider(d *schema.ResourceData) (interface{}, error) {
+	// Durations were already validated at plan time
+	minBackoff, _ := time.ParseDuration(d.Get("min_backoff").(string))
+	maxBackoff, _ := time.ParseDuration(d.Get("max_backoff").(string))
+	userCacheTTL, _ := time.ParseDuration(d.Get("user_cache_ttl").(string))
+	if maxBackoff < minBackoff {
+		return nil, fmt.Errorf("max_backoff (%s) must be greater than or equal to min_backoff (%s)", maxBackoff, minBackoff)
+	}
+
 	config := &Config{
-		APIToken: d.Get("api_token").(string),
+		APIToken:   d.Get("api_token").(string),
+		APIURL:     d.Get("api_url").(string),
+		MaxRetries: d.Get("max_retries").(int),
+		MinBackoff: minBackoff,
+		MaxBackoff: maxBackoff,
+
+		Parallelism: d.Get("parallelism").(int),
+
+		NeverKickBots:   d.Get("never_kick_bots").(bool),
+		NeverKickAdmins: d.Get("never_kick_admins").(bool),
+		MaxRemovals:     d.Get("max_removals").(int),
+
+		UserCache:    d.Get("user_cache").(bool),
+		UserCacheTTL: userCacheTTL,
+	}
+	config.loadClients()
+	if err := config.authenticate(); err != nil {
+		return nil, err
 	}
 	return config, nil
 }
+
+func validateAPIURL(v interface{}, k string) (ws []string, errors []error) {
+	u, err := url.Parse(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a valid URL: %s", k, err))
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		errors = append(errors, fmt.Errorf("%q must be an absolute http(s) URL, got %q", k, v.(string)))
+	}
+	return
+}
+
+func validateDuration(v interface{}, k string) (ws []string, errors []error) {
+	d, err := time.ParseDuration(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q must be a valid duration (i.e. '1s', '500ms'): %s", k, err))
+		return
+	}
+	if d < 0 {
+		errors = append(errors, fmt.Errorf("%q must not be negative, got %s", k, d))
+	}
+	return
+}
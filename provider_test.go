@@ -1,17 +1,23 @@
 package main
 
 import (
+	"os"
 	"testing"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/terraform"
 )
 
+var testAccProviders map[string]terraform.ResourceProvider
 var testAccProvider *schema.Provider
 
-//TODO: Need to add actual tests...
+// Acceptance tests run against the in-memory Slack API of fake_slack_test.go (see testAccFakeSlack),
+// they do not need network access nor a real Slack workspace.
 func init() {
 	testAccProvider = Provider().(*schema.Provider)
+	testAccProviders = map[string]terraform.ResourceProvider{
+		"slack": testAccProvider,
+	}
 }
 
 func TestProvider(t *testing.T) {
@@ -25,4 +31,9 @@ func TestProvider_impl(t *testing.T) {
 }
 
 func testAccPreCheck(t *testing.T) {
+	for _, k := range []string{"SLACK_API_URL", "SLACK_API_TOKEN"} {
+		if v := os.Getenv(k); v == "" {
+			t.Fatalf("%s must be set for acceptance tests, use testAccFakeSlack", k)
+		}
+	}
 }
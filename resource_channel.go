@@ -1,18 +1,64 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
-	"github.com/timdurward/slack"
+	"github.com/nlopes/slack"
+)
+
+// Prefix of slack_channel import IDs referencing a channel by name instead of ID
+const channelImportNamePrefix = "name:"
+
+// Values of the slack_channel action_on_destroy attribute
+const (
+	channelActionOnDestroyDelete           = "delete"
+	channelActionOnDestroyArchive          = "archive"
+	channelActionOnDestroyRenameAndArchive = "rename_and_archive"
+	channelActionOnDestroyNone             = "none"
+)
+
+// Scopes (or token type) each action_on_destroy requires, reported along with destruction errors
+var channelActionOnDestroyRequirements = map[string]string{
+	channelActionOnDestroyDelete:           "an admin user token (channels.delete is restricted to workspace admins)",
+	channelActionOnDestroyArchive:          "the channels:write scope (groups:write for private channels)",
+	channelActionOnDestroyRenameAndArchive: "the channels:write scope (groups:write for private channels)",
+}
+
+// Values of the slack_channel adopt_existing attribute
+const (
+	channelAdoptExistingNever        = "never"
+	channelAdoptExistingArchivedOnly = "archived_only"
+	channelAdoptExistingAny          = "any"
 )
 
+// Slack channel names can't be longer than 80 characters
+const channelNameMaxLength = 80
+
 func resourceChannel() *schema.Resource {
 	return &schema.Resource{
-		Create: resourceChannelCreate,
-		Read:   resourceChannelRead,
-		Update: resourceChannelUpdate,
-		Delete: resourceChannelDelete,
-		Exists: resourceChannelExists,
+		Create:        resourceChannelCreate,
+		Read:          resourceChannelRead,
+		Update:        resourceChannelUpdate,
+		Delete:        resourceChannelDelete,
+		Exists:        resourceChannelExists,
+		CustomizeDiff: resourceChannelCustomizeDiff,
+		Importer: &schema.ResourceImporter{
+			State: resourceChannelImport,
+		},
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceChannelV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceChannelStateUpgradeV0,
+			},
+		},
 
 		Schema: map[string]*schema.Schema{
 			"channel_name": &schema.Schema{
@@ -27,99 +73,299 @@ func resourceChannel() *schema.Resource {
 				Description: "Sets the topic for a channel",
 				Optional:    true,
 			},
-			"force_delete": &schema.Schema{
+			"channel_purpose": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "Sets the purpose for a channel",
+				Optional:    true,
+			},
+			"is_private": &schema.Schema{
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Whether the channel is private, changing it forces the creation of a new channel",
+				Optional:    true,
+				ForceNew:    true,
+			},
+			"is_archived": &schema.Schema{
+				Type:        schema.TypeBool,
+				Default:     false,
+				Description: "Whether the channel is archived, a channel archived out of Terraform shows up as a drift",
+				Optional:    true,
+			},
+			"adopt_existing": &schema.Schema{
+				Type:        schema.TypeString,
+				Default:     channelAdoptExistingArchivedOnly,
+				Description: "Whether an existing channel holding the same name is adopted on creation: 'never', 'archived_only' (it is unarchived) or 'any'",
+				Optional:    true,
+				ValidateFunc: validation.StringInSlice([]string{
+					channelAdoptExistingNever,
+					channelAdoptExistingArchivedOnly,
+					channelAdoptExistingAny,
+				}, false),
+			},
+			"adopted": &schema.Schema{
 				Type:        schema.TypeBool,
-				Default:     true,
-				Description: "Force the deletion of the channel instead of archiving it",
+				Description: "Whether the channel already existed and was adopted instead of being created",
+				Computed:    true,
+			},
+			"action_on_destroy": &schema.Schema{
+				Type:        schema.TypeString,
+				Default:     channelActionOnDestroyArchive,
+				Description: "What to do with the channel when the resource is destroyed: 'delete' it (requires an admin token, public channels only), 'archive' it, 'rename_and_archive' it (freeing its name for reuse) or do nothing ('none')",
 				Optional:    true,
+				ValidateFunc: validation.StringInSlice([]string{
+					channelActionOnDestroyDelete,
+					channelActionOnDestroyArchive,
+					channelActionOnDestroyRenameAndArchive,
+					channelActionOnDestroyNone,
+				}, false),
 			},
 		},
 	}
 }
 
+// Rejects the settings that could only fail at destroy time
+func resourceChannelCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	// channels.delete only knows about public channels, conversations.* has no delete method
+	if d.Get("is_private").(bool) && d.Get("action_on_destroy").(string) == channelActionOnDestroyDelete {
+		return fmt.Errorf("action_on_destroy = %q is not supported for private channels (is_private = true), Slack can only delete public channels: use %q or %q instead", channelActionOnDestroyDelete, channelActionOnDestroyArchive, channelActionOnDestroyRenameAndArchive)
+	}
+	return nil
+}
+
 func resourceChannelExists(d *schema.ResourceData, meta interface{}) (b bool, e error) {
 	return true, nil
 }
 
 func resourceChannelCreate(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	api := meta.(*Config).Client
+	name := d.Get("channel_name").(string)
+	isPrivate := d.Get("is_private").(bool)
 
 	// Create Slack Channel
-	channel, err := api.CreateChannel(d.Get("channel_name").(string))
-	if err != nil && err.Error() == "name_taken" {
-		// Channel most likely has to be unarchived
-		channels, err := api.GetChannels(false)
-		if err != nil {
+	channel, err := api.CreateConversation(name, isPrivate)
+	if isSlackError(err, "name_taken") {
+		if channel, err = adoptChannel(api, name, isPrivate, d.Get("adopt_existing").(string)); err != nil {
 			return err
 		}
-		for _, c := range channels {
-			if !c.IsArchived {
-				continue
-			}
-			if c.Name != d.Get("channel_name").(string) {
-				continue
-			}
-			channel, err := api.GetChannelInfo(c.ID)
-			if err != nil {
-				return err
-			}
-			err = api.UnarchiveChannel(channel.ID)
-			d.SetId(channel.ID)
-			if err != nil {
-				return err
-			}
-		}
-	} else if err == nil {
-		d.SetId(channel.ID)
+		d.Set("adopted", true)
+	} else if err != nil {
+		return slackErrorf("slack_channel create", err)
 	} else {
-		return err
+		d.Set("adopted", false)
 	}
+	d.SetId(channel.ID)
 
-	// Create Slack Channel Topic
-	if _, err := api.SetChannelTopic(d.Id(), d.Get("channel_topic").(string)); err != nil {
-		return err
+	// Create Slack Channel Topic and Purpose, unless the (adopted) channel already has them
+	if err := setChannelTopicAndPurpose(api, d, channel); err != nil {
+		return slackErrorf("slack_channel create", err)
+	}
+
+	if d.Get("is_archived").(bool) {
+		if err := api.ArchiveConversation(d.Id()); err != nil {
+			return slackErrorf("slack_channel create", err)
+		}
 	}
 
 	return nil
 }
 
 func resourceChannelRead(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	api := meta.(*Config).Client
 
 	// Checks if Slack Channel exists, if not remove resource from state
-	_, err := api.GetChannelInfo(d.Id())
+	channel, err := api.GetConversationInfo(d.Id(), false)
 	if err != nil {
-		d.SetId("")
-		return nil
+		if isSlackError(err, "channel_not_found") {
+			log.Printf("[WARN] Slack channel %s not found, removing it from state", d.Id())
+			d.SetId("")
+			return nil
+		}
+		// Any other error (i.e. network failure) must not make Terraform forget about the channel
+		return slackErrorf("slack_channel read", err)
 	}
 
+	d.Set("channel_name", channel.Name)
+	d.Set("channel_topic", channel.Topic.Value)
+	d.Set("channel_purpose", channel.Purpose.Value)
+	d.Set("is_private", channel.IsPrivate)
+	d.Set("is_archived", channel.IsArchived)
 	return nil
 }
 
 func resourceChannelUpdate(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	api := meta.(*Config).Client
 
-	name := d.Get("channel_name").(string)
-	if _, err := api.RenameChannel(d.Id(), name); err != nil {
-		return err
+	// An archived channel can't be modified, unarchive it first
+	if d.HasChange("is_archived") && !d.Get("is_archived").(bool) {
+		if err := api.UnArchiveConversation(d.Id()); err != nil {
+			return slackErrorf("slack_channel update", err)
+		}
+	}
+
+	// Only call the methods needed for what actually changed: renaming a channel to its own name fails
+	if d.HasChange("channel_name") {
+		name := d.Get("channel_name").(string)
+		if _, err := api.RenameConversation(d.Id(), name); err != nil {
+			return slackErrorf("slack_channel update", err)
+		}
+	}
+
+	if d.HasChange("channel_topic") {
+		if _, err := api.SetTopicOfConversation(d.Id(), d.Get("channel_topic").(string)); err != nil {
+			return slackErrorf("slack_channel update", err)
+		}
+	}
+
+	if d.HasChange("channel_purpose") {
+		if _, err := api.SetPurposeOfConversation(d.Id(), d.Get("channel_purpose").(string)); err != nil {
+			return slackErrorf("slack_channel update", err)
+		}
+	}
+
+	if d.HasChange("is_archived") && d.Get("is_archived").(bool) {
+		if err := api.ArchiveConversation(d.Id()); err != nil {
+			return slackErrorf("slack_channel update", err)
+		}
 	}
 	return nil
 }
 
 func resourceChannelDelete(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	api := meta.(*Config).Client
 
-	if d.Get("force_delete").(bool) {
-		// Deletes Slack Channel and clears state
-		if _, err := api.DeleteChannel(d.Id()); err != nil {
-			return err
+	var err error
+	action := d.Get("action_on_destroy").(string)
+	switch action {
+	case channelActionOnDestroyDelete:
+		// conversations.* has no equivalent of the (undocumented) channels.delete method, only the fork implements it
+		_, err = meta.(*Config).LegacyClient.DeleteChannel(d.Id())
+	case channelActionOnDestroyArchive:
+		err = api.ArchiveConversation(d.Id())
+		if isSlackError(err, "already_archived") {
+			err = nil
 		}
-	} else {
-		// Archives Slack Channel
-		if err := api.ArchiveChannel(d.Id()); err != nil {
+	case channelActionOnDestroyRenameAndArchive:
+		err = renameAndArchiveChannel(api, d.Id(), time.Now())
+	case channelActionOnDestroyNone:
+		log.Printf("[INFO] action_on_destroy is %q, leaving Slack channel %s untouched", action, d.Id())
+	}
+	if err != nil {
+		return slackErrorf(fmt.Sprintf("slack_channel delete (action_on_destroy = %q requires %s)", action, channelActionOnDestroyRequirements[action]), err)
+	}
+
+	return nil
+}
+
+// Suffixes the channel name with a timestamp, so that its name can be reused right away, then archives it
+func renameAndArchiveChannel(api *slack.Client, channelID string, now time.Time) error {
+	channel, err := api.GetConversationInfo(channelID, false)
+	if err != nil {
+		return err
+	}
+
+	// An archived channel can't be renamed
+	if channel.IsArchived {
+		if err = api.UnArchiveConversation(channelID); err != nil {
 			return err
 		}
 	}
 
+	suffix := "-" + now.UTC().Format("20060102150405")
+	name := channel.Name
+	if len(name)+len(suffix) > channelNameMaxLength {
+		name = name[:channelNameMaxLength-len(suffix)]
+	}
+	if _, err = api.RenameConversation(channelID, name+suffix); err != nil {
+		return err
+	}
+
+	return api.ArchiveConversation(channelID)
+}
+
+// Returns the existing channel holding a name, unarchiving it if needed, provided that adoptExisting allows adopting it
+func adoptChannel(api *slack.Client, name string, isPrivate bool, adoptExisting string) (*slack.Channel, error) {
+	channel, err := findChannelByName(api, name)
+	if err != nil {
+		return nil, slackErrorf(fmt.Sprintf("channel name %s is taken, but the channel holding it could not be found", name), err)
+	}
+
+	state := "active"
+	if channel.IsArchived {
+		state = "archived"
+	}
+	switch {
+	case adoptExisting == channelAdoptExistingNever,
+		adoptExisting == channelAdoptExistingArchivedOnly && !channel.IsArchived:
+		return nil, fmt.Errorf("channel name %s is already taken by the %s channel %s, and adopt_existing = %q does not allow adopting it", name, state, channel.ID, adoptExisting)
+	case channel.IsPrivate != isPrivate:
+		return nil, fmt.Errorf("channel name %s is already taken by the %s channel %s, which can't be adopted since its is_private is %t", name, state, channel.ID, channel.IsPrivate)
+	}
+
+	if channel.IsArchived {
+		if err = api.UnArchiveConversation(channel.ID); err != nil {
+			return nil, slackErrorf(fmt.Sprintf("could not unarchive the adopted channel %s", channel.ID), err)
+		}
+	}
+	log.Printf("[INFO] Adopted the existing %s Slack channel %s (%s)", state, name, channel.ID)
+	return channel, nil
+}
+
+// Sets the topic and purpose of a channel, only calling the methods needed for the values differing from the channel's current ones
+func setChannelTopicAndPurpose(api *slack.Client, d *schema.ResourceData, channel *slack.Channel) error {
+	if topic := d.Get("channel_topic").(string); topic != channel.Topic.Value {
+		if _, err := api.SetTopicOfConversation(channel.ID, topic); err != nil {
+			return err
+		}
+	}
+	if purpose := d.Get("channel_purpose").(string); purpose != channel.Purpose.Value {
+		if _, err := api.SetPurposeOfConversation(channel.ID, purpose); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Imports a channel either by ID (i.e. "C0123ABC") or by name (i.e. "name:engineering")
+func resourceChannelImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	api := meta.(*Config).Client
+
+	if strings.HasPrefix(d.Id(), channelImportNamePrefix) {
+		name := strings.TrimPrefix(d.Id(), channelImportNamePrefix)
+		channel, err := findChannelByName(api, name)
+		if err != nil {
+			return nil, slackErrorf(fmt.Sprintf("could not import channel named %s", name), err)
+		}
+		d.SetId(channel.ID)
+	}
+
+	// Provider side settings, the imported channel gets the defaults of the schema
+	for _, k := range []string{"action_on_destroy", "adopt_existing"} {
+		d.Set(k, resourceChannel().Schema[k].Default)
+	}
+	d.Set("adopted", false)
+	return []*schema.ResourceData{d}, nil
+}
+
+// Returns the channel with the given name, looking through every page of conversations.list, archived channels included
+func findChannelByName(api *slack.Client, name string) (*slack.Channel, error) {
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: "false",
+		Limit:           conversationsPageLimit,
+		Types:           []string{"public_channel", "private_channel"},
+	}
+	for {
+		channels, nextCursor, err := api.GetConversations(params)
+		if err != nil {
+			return nil, err
+		}
+		for i := range channels {
+			if channels[i].Name == name {
+				return &channels[i], nil
+			}
+		}
+		if nextCursor == "" {
+			return nil, &slackError{Code: "channel_not_found"}
+		}
+		params.Cursor = nextCursor
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// resourceChannelV0 is the slack_channel schema before force_delete was replaced by action_on_destroy
+func resourceChannelV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"channel_name": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"channel_topic": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"channel_purpose": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"is_private": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+			},
+			"is_archived": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+			"force_delete": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+		},
+	}
+}
+
+// Converts force_delete into the equivalent action_on_destroy
+func resourceChannelStateUpgradeV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	action := channelActionOnDestroyArchive
+	// force_delete used to default to true, a missing value meant deleting the channel
+	if forceDelete, ok := rawState["force_delete"].(bool); !ok || forceDelete {
+		action = channelActionOnDestroyDelete
+	}
+	log.Printf("[INFO] Migrating slack_channel %v force_delete = %v to action_on_destroy = %q", rawState["id"], rawState["force_delete"], action)
+
+	delete(rawState, "force_delete")
+	rawState["action_on_destroy"] = action
+	return rawState, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceChannelStateUpgradeV0(t *testing.T) {
+	cases := map[string]struct {
+		rawState map[string]interface{}
+		expected string
+	}{
+		"force_delete true": {
+			rawState: map[string]interface{}{"id": "C123", "channel_name": "foo", "force_delete": true},
+			expected: "delete",
+		},
+		"force_delete false": {
+			rawState: map[string]interface{}{"id": "C123", "channel_name": "foo", "force_delete": false},
+			expected: "archive",
+		},
+		"force_delete missing": {
+			rawState: map[string]interface{}{"id": "C123", "channel_name": "foo"},
+			expected: "delete",
+		},
+	}
+
+	for name, tc := range cases {
+		actual, err := resourceChannelStateUpgradeV0(tc.rawState, nil)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		expected := map[string]interface{}{"id": "C123", "channel_name": "foo", "action_on_destroy": tc.expected}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("%s: expected %v, got %v", name, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccSlackChannel_basic(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelConfig("tf-acc-basic", "Managed by terraform", "delete"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+						if c.Name != "tf-acc-basic" {
+							return fmt.Errorf("expected channel name tf-acc-basic, got %s", c.Name)
+						}
+						if c.Topic != "Managed by terraform" {
+							return fmt.Errorf("expected channel topic to be set, got %q", c.Topic)
+						}
+						return nil
+					}),
+					resource.TestCheckResourceAttr("slack_channel.test", "channel_name", "tf-acc-basic"),
+					resource.TestCheckResourceAttr("slack_channel.test", "adopted", "false"),
+				),
+			},
+			{
+				Config: testAccSlackChannelConfig("tf-acc-renamed", "Managed by terraform", "delete"),
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					if c.Name != "tf-acc-renamed" {
+						return fmt.Errorf("expected channel to be renamed to tf-acc-renamed, got %s", c.Name)
+					}
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_topicAndPurpose(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelPurposeConfig("tf-acc-purpose", "Topic", "Purpose"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("slack_channel.test", "channel_purpose", "Purpose"),
+					testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+						if c.Topic != "Topic" || c.Purpose != "Purpose" {
+							return fmt.Errorf("expected topic and purpose to be set, got %q and %q", c.Topic, c.Purpose)
+						}
+						return nil
+					}),
+				),
+			},
+			{
+				Config: testAccSlackChannelPurposeConfig("tf-acc-purpose", "New topic", "New purpose"),
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					if c.Topic != "New topic" || c.Purpose != "New purpose" {
+						return fmt.Errorf("expected topic and purpose to be updated, got %q and %q", c.Topic, c.Purpose)
+					}
+					if n := f.Calls("conversations.rename"); n != 0 {
+						return fmt.Errorf("expected the channel not to be renamed, conversations.rename was called %d times", n)
+					}
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_archiveOnDestroy(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			c := f.ChannelByName("tf-acc-archive")
+			if c == nil {
+				return fmt.Errorf("channel tf-acc-archive was deleted instead of archived")
+			}
+			if !c.IsArchived {
+				return fmt.Errorf("channel tf-acc-archive was not archived")
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelConfig("tf-acc-archive", "", "archive"),
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					if c.IsArchived {
+						return fmt.Errorf("channel should not be archived yet")
+					}
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_renameAndArchiveOnDestroy(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	var channelID string
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			c := f.Channel(channelID)
+			if c == nil || !c.IsArchived {
+				return fmt.Errorf("channel %s was not archived", channelID)
+			}
+			if !strings.HasPrefix(c.Name, "tf-acc-rename-") {
+				return fmt.Errorf("expected channel to be renamed with a timestamp suffix, got %s", c.Name)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelConfig("tf-acc-rename", "", "rename_and_archive"),
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					channelID = c.ID
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_noneOnDestroy(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if c := f.ChannelByName("tf-acc-none"); c == nil || c.IsArchived {
+				return fmt.Errorf("channel tf-acc-none should have been left untouched")
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelConfig("tf-acc-none", "", "none"),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_adoptArchived(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	archivedID := f.AddChannel(&fakeChannel{Name: "tf-acc-adopt", IsArchived: true, Topic: "Adopted"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelConfig("tf-acc-adopt", "Adopted", "delete"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("slack_channel.test", "id", archivedID),
+					resource.TestCheckResourceAttr("slack_channel.test", "adopted", "true"),
+					testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+						if c.IsArchived {
+							return fmt.Errorf("adopted channel should have been unarchived")
+						}
+						if c.Topic != "Adopted" {
+							return fmt.Errorf("expected adopted channel topic to be kept, got %q", c.Topic)
+						}
+						if n := f.Calls("conversations.setTopic"); n != 0 {
+							return fmt.Errorf("expected the adopted channel topic not to be set again, conversations.setTopic was called %d times", n)
+						}
+						return nil
+					}),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_adoptActive(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	activeID := f.AddChannel(&fakeChannel{Name: "tf-acc-active"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackChannelAdoptConfig("tf-acc-active", "archived_only"),
+				ExpectError: regexp.MustCompile(`channel name tf-acc-active is already taken by the active channel ` + activeID),
+			},
+			{
+				Config: testAccSlackChannelAdoptConfig("tf-acc-active", "any"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("slack_channel.test", "id", activeID),
+					resource.TestCheckResourceAttr("slack_channel.test", "adopted", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_adoptNever(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	archivedID := f.AddChannel(&fakeChannel{Name: "tf-acc-never", IsArchived: true})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackChannelAdoptConfig("tf-acc-never", "never"),
+				ExpectError: regexp.MustCompile(`channel name tf-acc-never is already taken by the archived channel ` + archivedID),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_missingScope(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	f.MissingScopes = map[string]string{"conversations.create": "channels:manage"}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackChannelConfig("tf-acc-missing-scope", "", "archive"),
+				ExpectError: regexp.MustCompile(`slack_channel create requires scope channels:manage; token has channels:read,users:read`),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_private(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	var privateID string
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelPrivateConfig("tf-acc-private", true, "rename_and_archive"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("slack_channel.test", "is_private", "true"),
+					testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+						if !c.IsPrivate {
+							return fmt.Errorf("expected channel %s to be private", c.Name)
+						}
+						privateID = c.ID
+						return nil
+					}),
+				),
+			},
+			{
+				// Slack can't delete private channels, renaming the replaced one frees its name for the new one
+				Config: testAccSlackChannelPrivateConfig("tf-acc-private", false, "delete"),
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					if c.IsPrivate {
+						return fmt.Errorf("expected channel %s to be public", c.Name)
+					}
+					if p := f.Channel(privateID); c.ID == privateID || p == nil || !p.IsArchived {
+						return fmt.Errorf("expected private channel %s to be replaced and archived", privateID)
+					}
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_privateDelete(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: func(s *terraform.State) error {
+			if c := f.ChannelByName("tf-acc-private-delete"); c != nil {
+				return fmt.Errorf("expected channel tf-acc-private-delete not to be created, got %s", c.ID)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				// Slack can't delete private channels, planning it fails before anything is created
+				Config:      testAccSlackChannelPrivateConfig("tf-acc-private-delete", true, "delete"),
+				ExpectError: regexp.MustCompile(`action_on_destroy = "delete" is not supported for private channels`),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_drift(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	config := testAccSlackChannelConfig("tf-acc-drift", "", "delete")
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+			},
+			{
+				// Renamed and archived from the Slack UI
+				PreConfig: func() {
+					f.UpdateChannel(f.ChannelByName("tf-acc-drift").ID, func(c *fakeChannel) {
+						c.Name = "renamed-in-ui"
+						c.IsArchived = true
+					})
+				},
+				Config:             config,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config,
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					if c.Name != "tf-acc-drift" || c.IsArchived {
+						return fmt.Errorf("expected drift to be fixed, got channel %s (archived: %t)", c.Name, c.IsArchived)
+					}
+					return nil
+				}),
+			},
+			{
+				// Deleted from the Slack UI
+				PreConfig: func() {
+					f.DeleteChannel(f.ChannelByName("tf-acc-drift").ID)
+				},
+				Config: config,
+				Check: testAccCheckSlackChannel(f, "slack_channel.test", func(c *fakeChannel) error {
+					if c.Name != "tf-acc-drift" {
+						return fmt.Errorf("expected channel to be created again, got %s", c.Name)
+					}
+					return nil
+				}),
+			},
+		},
+	})
+}
+
+func TestAccSlackChannel_import(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackChannelDestroy(f),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackChannelConfig("tf-acc-import", "Imported", "delete"),
+			},
+			{
+				ResourceName:      "slack_channel.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				// Not stored in Slack, import always uses the default
+				ImportStateVerifyIgnore: []string{"action_on_destroy"},
+			},
+			{
+				ResourceName:            "slack_channel.test",
+				ImportState:             true,
+				ImportStateId:           "name:tf-acc-import",
+				ImportStateVerify:       true,
+				ImportStateVerifyIgnore: []string{"action_on_destroy"},
+			},
+		},
+	})
+}
+
+// testAccCheckSlackChannel runs check against the fake Slack conversation backing the given resource
+func testAccCheckSlackChannel(f *fakeSlack, n string, check func(c *fakeChannel) error) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("not found: %s", n)
+		}
+		c := f.Channel(rs.Primary.ID)
+		if c == nil {
+			return fmt.Errorf("channel %s does not exist", rs.Primary.ID)
+		}
+		return check(c)
+	}
+}
+
+func testAccCheckSlackChannelDestroy(f *fakeSlack) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		for _, rs := range s.RootModule().Resources {
+			if rs.Type != "slack_channel" {
+				continue
+			}
+			if c := f.Channel(rs.Primary.ID); c != nil {
+				return fmt.Errorf("channel %s (%s) still exists", c.Name, c.ID)
+			}
+		}
+		return nil
+	}
+}
+
+func testAccSlackChannelConfig(name, topic, actionOnDestroy string) string {
+	return fmt.Sprintf(`
+resource "slack_channel" "test" {
+  channel_name      = %q
+  channel_topic     = %q
+  action_on_destroy = %q
+}
+`, name, topic, actionOnDestroy)
+}
+
+func testAccSlackChannelPrivateConfig(name string, private bool, actionOnDestroy string) string {
+	return fmt.Sprintf(`
+resource "slack_channel" "test" {
+  channel_name      = %q
+  is_private        = %t
+  action_on_destroy = %q
+}
+`, name, private, actionOnDestroy)
+}
+
+func testAccSlackChannelPurposeConfig(name, topic, purpose string) string {
+	return fmt.Sprintf(`
+resource "slack_channel" "test" {
+  channel_name      = %q
+  channel_topic     = %q
+  channel_purpose   = %q
+  action_on_destroy = "delete"
+}
+`, name, topic, purpose)
+}
+
+func testAccSlackChannelAdoptConfig(name, adoptExisting string) string {
+	return fmt.Sprintf(`
+resource "slack_channel" "test" {
+  channel_name      = %q
+  adopt_existing    = %q
+  action_on_destroy = "delete"
+}
+`, name, adoptExisting)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/nlopes/slack"
+)
+
+func resourceConversationMember() *schema.Resource {
+	return &schema.Resource{
+		Create: resourceConversationMemberCreate,
+		Read:   resourceConversationMemberRead,
+		Delete: resourceConversationMemberDelete,
+
+		Schema: map[string]*schema.Schema{
+			"conversation_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "The conversationID of the Slack conversation",
+				Required:    true,
+				ForceNew:    true,
+			},
+			"member": &schema.Schema{
+				Type:         schema.TypeString,
+				Description:  "Slack user to invite, the following formats are supported: 'email:user@some.domain', 'id:userId', 'name:username'",
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validateSingleMemberExpression,
+			},
+			"user_id": &schema.Schema{
+				Type:        schema.TypeString,
+				Description: "ID of the member",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Like validateMemberExpression, rejecting the expressions matching a group of users
+func validateSingleMemberExpression(v interface{}, k string) (ws []string, errors []error) {
+	if ws, errors = validateMemberExpression(v, k); len(errors) > 0 {
+		return
+	}
+	if strings.HasPrefix(v.(string), memberExpressionUserGroupPrefix) {
+		errors = append(errors, fmt.Errorf("%q: %s expressions match a group of users, use slack_conversation_members instead", k, memberExpressionUserGroupPrefix))
+	}
+	return
+}
+
+func resourceConversationMemberCreate(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*Config)
+	api := config.Client
+	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
+	if err != nil {
+		return slackErrorf("slack_conversation_member create", err)
+	}
+
+	u, err := getUserInfo(config, d.Get("member").(string))
+	if err != nil {
+		return slackErrorf(fmt.Sprintf("could not get user %s information", d.Get("member").(string)), err)
+	}
+	if err = inviteUsers(config, c, []*slack.User{u}); err != nil {
+		return err
+	}
+
+	d.SetId(c.ID + ":" + u.ID)
+	d.Set("user_id", u.ID)
+	return resourceConversationMemberRead(d, meta)
+}
+
+func resourceConversationMemberRead(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*Config)
+	api := config.Client
+	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
+	if err != nil {
+		if isSlackError(err, "channel_not_found") {
+			log.Printf("[WARN] Slack conversation %s not found, removing the membership of %s from state", d.Get("conversation_id"), d.Get("user_id"))
+			d.SetId("")
+			return nil
+		}
+		return slackErrorf("slack_conversation_member read", err)
+	}
+
+	conversationMembers, err := getConversationMembers(api, c.ID)
+	if err != nil {
+		return slackErrorf("slack_conversation_member read", err)
+	}
+	userID := d.Get("user_id").(string)
+	if containsString(conversationMembers, userID) {
+		return nil
+	}
+
+	// Deactivated accounts are never invited, their membership is not a drift
+	u, err := config.Users.GetUserInfo(userID)
+	if err == nil && u.Deleted {
+		return nil
+	}
+	log.Printf("[WARN] User %s is not a member of the Slack conversation %s anymore, removing the membership from state", userID, c.ID)
+	d.SetId("")
+	return nil
+}
+
+func resourceConversationMemberDelete(d *schema.ResourceData, meta interface{}) error {
+	config := meta.(*Config)
+	api := config.Client
+	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
+	if err != nil {
+		return slackErrorf("slack_conversation_member delete", err)
+	}
+
+	u, err := config.Users.GetUserInfo(d.Get("user_id").(string))
+	if err != nil {
+		if isSlackError(err, "user_not_found") {
+			return nil
+		}
+		return slackErrorf(fmt.Sprintf("could not get user %s information", d.Get("user_id").(string)), err)
+	}
+	// never_kick_bots and never_kick_admins protect from being kicked out as intruders, not from destroying an explicit membership.
+	// Only the token owner stays, leaving the conversation would lose access to it.
+	if u.ID == config.UserID {
+		log.Printf("[WARN] Not kicking the token owner %s (%s) out of conversation %s, only removing the membership from state", u.Name, u.ID, c.ID)
+		return nil
+	}
+	return kickUsers(api, c, []*slack.User{u})
+}
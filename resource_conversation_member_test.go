@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestAccSlackConversationMember_basic(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	bob := f.AddUser(&fakeUser{Name: "bob"})
+	// Bob is managed by someone else, he is left alone
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-member", Members: []string{bob}})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+			testAccCheckSlackConversationMembersExact(f, channelID, bob),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMemberConfig(channelID, "email:alice@example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice, bob),
+					resource.TestCheckResourceAttr("slack_conversation_member.test", "user_id", alice),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMember_drift(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-member-drift"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMemberConfig(channelID, "id:"+alice),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice),
+					// Kicked out from the Slack UI
+					func(_ *terraform.State) error {
+						f.UpdateChannel(channelID, func(c *fakeChannel) { c.Members = nil })
+						return nil
+					},
+				),
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: testAccSlackConversationMemberConfig(channelID, "id:"+alice),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMember_userGroup(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-member-usergroup"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackConversationMemberConfig(channelID, "usergroup:eng"),
+				ExpectError: regexp.MustCompile("usergroup: expressions match a group of users"),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMember_destroyIgnoresNeverKick(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	bot := f.AddUser(&fakeUser{Name: "ci-bot", IsBot: true})
+	admin := f.AddUser(&fakeUser{Name: "admin", IsAdmin: true})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-member-never-kick"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		// The memberships were explicitly managed, destroying them kicks the bot and the admin out
+		// but the token owner stays in the conversation
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccCheckSlackConversationMembersKicked(f, channelID, bot, admin),
+			testAccCheckSlackConversationMembersExact(f, channelID, f.OwnerID()),
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "slack" {
+  never_kick_bots   = true
+  never_kick_admins = true
+}
+
+resource "slack_conversation_member" "bot" {
+  conversation_id = %q
+  member          = "id:%s"
+}
+
+resource "slack_conversation_member" "admin" {
+  conversation_id = %q
+  member          = "id:%s"
+}
+
+resource "slack_conversation_member" "owner" {
+  conversation_id = %q
+  member          = "id:%s"
+}
+`, channelID, bot, channelID, admin, channelID, f.OwnerID()),
+				Check: testAccCheckSlackConversationMembersExact(f, channelID, bot, admin, f.OwnerID()),
+			},
+		},
+	})
+}
+
+func testAccSlackConversationMemberConfig(channelID, member string) string {
+	return fmt.Sprintf(`
+resource "slack_conversation_member" "test" {
+  conversation_id = %q
+  member          = %q
+}
+`, channelID, member)
+}
@@ -2,14 +2,41 @@ package main
 
 import (
 	"fmt"
-	"reflect"
-	"sort"
+	"log"
 	"strings"
-	
+	"sync"
+
 	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/nlopes/slack"
 )
 
+// conversations.invite accepts up to 1000 users per call (a variable so that tests can use smaller batches)
+var inviteBatchSize = 1000
+
+// Suffix of the slack_conversation_members IDs
+const conversationMembersIDSuffix = "-members"
+
+// Member expressions written by the import, chosen with an import ID suffix (i.e. "C0123ABC:email")
+const (
+	conversationMembersImportIDFormat    = "id"
+	conversationMembersImportEmailFormat = "email"
+)
+
+// Values of the slack_conversation_members action_on_destroy attribute
+const (
+	membersActionOnDestroyKickManaged         = "kick_managed"
+	membersActionOnDestroyKickAllButProtected = "kick_all_but_protected"
+	membersActionOnDestroyNone                = "none"
+)
+
+// Values of the slack_conversation_members on_unresolvable_member attribute
+const (
+	onUnresolvableMemberError       = "error"
+	onUnresolvableMemberWarnAndSkip = "warn_and_skip"
+	onUnresolvableMemberRemove      = "remove"
+)
+
 func resourceConversationMembers() *schema.Resource {
 	return &schema.Resource{
 		Create: resourceConversationMembersCreate,
@@ -17,6 +44,21 @@ func resourceConversationMembers() *schema.Resource {
 		Update: resourceConversationMembersUpdate,
 		Delete: resourceConversationMembersDelete,
 
+		CustomizeDiff: resourceConversationMembersCustomizeDiff,
+
+		Importer: &schema.ResourceImporter{
+			State: resourceConversationMembersImport,
+		},
+
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceConversationMembersV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: resourceConversationMembersStateUpgradeV0,
+			},
+		},
+
 		Schema: map[string]*schema.Schema{
 			"conversation_id": &schema.Schema{
 				Type:        schema.TypeString,
@@ -24,20 +66,29 @@ func resourceConversationMembers() *schema.Resource {
 				Required:    true,
 			},
 			"members": &schema.Schema{
-				Type:        schema.TypeList,
-				Elem:        &schema.Schema{Type: schema.TypeString},
-				Description: "List of Slack users to invite, the following formats are supported: 'email:user@some.domain', 'id:userId'",
+				Type: schema.TypeSet,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateMemberExpression,
+				},
+				Set:         schema.HashString,
+				Description: "Set of Slack users to invite, the following formats are supported: 'email:user@some.domain', 'id:userId', 'name:username', 'usergroup:handleOrId' (the current members of a user group)",
 				Required:    true,
 				MinItems:    1,
-				// TODO: validate that the ":" separator is present, once ValidateFunc is supported on lists
-				// ValidateFunc: validation.StringInSlice([]string{"foo:"}, false),
 			},
 			"members_ids": &schema.Schema{
-				Type:        schema.TypeList,
+				Type:        schema.TypeSet,
 				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         schema.HashString,
 				Description: "IDs of the members",
 				Computed:    true,
 			},
+			"resolved_members": &schema.Schema{
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "IDs of the managed members present in the conversation, mapped to the member expression they were resolved from",
+				Computed:    true,
+			},
 			"authoritative": &schema.Schema{
 				Type:        schema.TypeBool,
 				Optional:    true,
@@ -45,191 +96,519 @@ func resourceConversationMembers() *schema.Resource {
 				Default:     false,
 				Description: "if set to true, any member not present within the members attributes will be forcibly kicked out from the conversation (except for the token owner) (default is false)",
 			},
+			"protected_members": &schema.Schema{
+				Type: schema.TypeSet,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validateMemberExpression,
+				},
+				Set:         schema.HashString,
+				Optional:    true,
+				Description: "Set of Slack users (same formats as members) that are never kicked out of the conversation, even if authoritative is set to true",
+			},
+			"action_on_destroy": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  membersActionOnDestroyKickManaged,
+				ValidateFunc: validation.StringInSlice([]string{
+					membersActionOnDestroyKickManaged,
+					membersActionOnDestroyKickAllButProtected,
+					membersActionOnDestroyNone,
+				}, false),
+				Description: "What happens to the conversation members on resource destruction: 'kick_managed' kicks out the managed members, 'kick_all_but_protected' kicks out every member but the protected ones, 'none' only removes the resource from the state",
+			},
+			"max_removals": &schema.Schema{
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(-1),
+				Description:  "Maximum number of users an apply, destruction included, may kick out of the conversation, -1 means no limit (defaults to the provider's max_removals)",
+			},
+			"pending_removals": &schema.Schema{
+				Type:        schema.TypeMap,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "IDs of the users the next apply kicks out of the conversation, mapped to their name (kept by the apply, emptied by the next refresh)",
+				Computed:    true,
+			},
+			"on_unresolvable_member": &schema.Schema{
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  onUnresolvableMemberError,
+				ValidateFunc: validation.StringInSlice([]string{
+					onUnresolvableMemberError,
+					onUnresolvableMemberWarnAndSkip,
+					onUnresolvableMemberRemove,
+				}, false),
+				Description: "What happens when a member expression matches no user (i.e. deleted user, typo'd email): 'error' fails the apply, 'warn_and_skip' ignores the expression, 'remove' ignores the expression and kicks out the users it previously matched",
+			},
+			"unresolved_members": &schema.Schema{
+				Type:        schema.TypeSet,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Set:         schema.HashString,
+				Description: "Member expressions matching no user",
+				Computed:    true,
+			},
 		},
 	}
 }
 
 // Returns (*slack.User, error) from an email
-func getUserByEmail(api *slack.Client, email string) (*slack.User, error) {
-	user, err := api.GetUserByEmail(email)
+func getUserByEmail(users *userDirectory, email string) (*slack.User, error) {
+	user, err := users.GetUserByEmail(email)
 	if err != nil {
 		return nil, err
 	}
 	return user, nil
 }
 
-// Returns (*slack.User, error) from a user expression (i.e. "id:myId", "email:my@email.corp")
-func getUserInfo(api *slack.Client, userExpression string) (*slack.User, error) {
-	userIdentifier := strings.SplitAfter(userExpression, ":")[1]
-	switch {
-	case strings.Contains(userExpression, "email:"):
-		return getUserByEmail(api, userIdentifier)
-	case strings.Contains(userExpression, "id:"):
-		return api.GetUserInfo(userIdentifier)
-	}
-	return nil, fmt.Errorf("only 'id:*' and 'email:*' member expressions are supported: %s", userExpression)
-}
-
-func getUsersToKickAuthoritative(api *slack.Client, c *slack.Channel, managedUsers []*slack.User) ([]*slack.User, error) {
-    intruders := make([]*slack.User, 0)
-	
-	conversationMembers, _, err := api.GetUsersInConversation(&slack.GetUsersInConversationParameters{
-		ChannelID: c.ID,
-		Cursor:    "", // TODO: implement a cursor for paginated API reads
-		Limit:     0,
-	})
+// Returns (*slack.User, error) from a user expression (i.e. "id:myId", "email:my@email.corp", "name:myName")
+func getUserInfo(config *Config, userExpression string) (*slack.User, error) {
+	prefix, userIdentifier, err := parseMemberExpression(userExpression)
 	if err != nil {
-		return nil, fmt.Errorf("(kickUsers) could not get the list of users in the conversation %s! %s", c.Name, err)
+		return nil, err
 	}
-	
-	for _, cmId := range conversationMembers {
-		for i, m := range managedUsers {
-			if m.ID == cmId {
-				break
+	switch prefix {
+	case memberExpressionEmailPrefix:
+		return getUserByEmail(config.Users, userIdentifier)
+	case memberExpressionIDPrefix:
+		return config.Users.GetUserInfo(userIdentifier)
+	case memberExpressionNamePrefix:
+		return findUser(config.Users, "name", userIdentifier, func(u slack.User) bool { return u.Name == userIdentifier })
+	}
+	return nil, fmt.Errorf("member expression %s matches a group of users, not a single user", userExpression)
+}
+
+// Returns the current members of a user group, from its handle or ID
+func getUserGroupUsers(config *Config, handleOrID string) ([]*slack.User, error) {
+	groups, err := config.Client.GetUserGroups()
+	if err != nil {
+		return nil, slackErrorf("could not list the user groups", err)
+	}
+	for _, g := range groups {
+		if g.ID != handleOrID && g.Handle != handleOrID {
+			continue
+		}
+		ids, err := config.Client.GetUserGroupMembers(g.ID)
+		if err != nil {
+			return nil, slackErrorf(fmt.Sprintf("could not get the members of the user group %s", g.Handle), err)
+		}
+		users := make([]*slack.User, len(ids))
+		for i, id := range ids {
+			if users[i], err = config.Users.GetUserInfo(id); err != nil {
+				return nil, slackErrorf(fmt.Sprintf("could not get user %s information", id), err)
 			}
-			if i == len(managedUsers)-1 {
-				intruder, err := api.GetUserInfo(cmId)
-				if err != nil {
-					return nil, fmt.Errorf("could not get intruder user %s information: %s", cmId, err)
-				}
-				intruders = append(intruders, intruder)
+		}
+		return users, nil
+	}
+	return nil, &unresolvableMemberError{expression: memberExpressionUserGroupPrefix + handleOrID, reason: "no user group has this handle or ID"}
+}
+
+// unresolvableMemberError is returned for member expressions matching no user (or user group)
+type unresolvableMemberError struct {
+	expression string
+	reason     string
+}
+
+func (e *unresolvableMemberError) Error() string {
+	return fmt.Sprintf("member %s can not be resolved: %s", e.expression, e.reason)
+}
+
+// Returns true if err is an unresolvableMemberError
+func isUnresolvableMember(err error) bool {
+	_, ok := err.(*unresolvableMemberError)
+	return ok
+}
+
+// Returns the users matching a member expression, a "usergroup:" expression matches every member of the group
+func getMemberUsers(config *Config, memberExpression string) ([]*slack.User, error) {
+	if strings.HasPrefix(memberExpression, memberExpressionUserGroupPrefix) {
+		return getUserGroupUsers(config, strings.TrimPrefix(memberExpression, memberExpressionUserGroupPrefix))
+	}
+	u, err := getUserInfo(config, memberExpression)
+	if err != nil {
+		if e, ok := parseSlackError(err); ok && (e.Code == "user_not_found" || e.Code == "users_not_found") {
+			return nil, &unresolvableMemberError{expression: memberExpression, reason: e.Code}
+		}
+		return nil, err
+	}
+	return []*slack.User{u}, nil
+}
+
+// memberResolution is the outcome of resolving a single member expression
+type memberResolution struct {
+	expression string
+	users      []*slack.User
+	err        error
+}
+
+// Resolves member expressions with at most config.Parallelism concurrent lookups, in the order of expressions.
+// Every worker goes through the same rate limited client.
+func resolveMemberExpressions(config *Config, expressions []string) []memberResolution {
+	results := make([]memberResolution, len(expressions))
+	workers := config.Parallelism
+	if workers > len(expressions) {
+		workers = len(expressions)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				users, err := getMemberUsers(config, expressions[i])
+				results[i] = memberResolution{expression: expressions[i], users: users, err: err}
+			}
+		}()
+	}
+	for i := range expressions {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// Returns a single error reporting every failed member expression
+func memberResolutionErrors(errs []string) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s", errs[0])
+	}
+	return fmt.Errorf("%d members could not be resolved:\n  - %s", len(errs), strings.Join(errs, "\n  - "))
+}
+
+// Returns the users matching a set of member expressions and the expressions matching no user,
+// which are an error unless onUnresolvable says otherwise
+func getUsersInfo(config *Config, memberExpressions *schema.Set, onUnresolvable string) ([]*slack.User, []string, error) {
+	expressions := make([]string, 0, memberExpressions.Len())
+	for _, e := range memberExpressions.List() {
+		expressions = append(expressions, e.(string))
+	}
+
+	users := make([]*slack.User, 0, len(expressions))
+	unresolved := make([]string, 0)
+	errs := make([]string, 0)
+	for _, r := range resolveMemberExpressions(config, expressions) {
+		if r.err != nil {
+			switch {
+			case !isUnresolvableMember(r.err):
+				errs = append(errs, slackErrorf("member "+r.expression, r.err).Error())
+			case onUnresolvable == onUnresolvableMemberError:
+				errs = append(errs, fmt.Sprintf("%s (on_unresolvable_member = %q)", r.err, onUnresolvable))
+			default:
+				log.Printf("[WARN] Skipping the %s (on_unresolvable_member = %q)", r.err, onUnresolvable)
+				unresolved = append(unresolved, r.expression)
 			}
+			continue
 		}
+		for _, u := range r.users {
+			if !containsUser(users, u) {
+				users = append(users, u)
+			}
+		}
+	}
+	if err := memberResolutionErrors(errs); err != nil {
+		return nil, nil, err
+	}
+	return users, unresolved, nil
+}
+
+// Returns true if user is one of users
+func containsUser(users []*slack.User, user *slack.User) bool {
+	for _, u := range users {
+		if u.ID == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns list without s
+func removeString(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, e := range list {
+		if e != s {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Returns true if s is one of list
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the users of protected_members, an unresolvable one protects nobody
+func getProtectedUsers(config *Config, d conversationMembersData) ([]*slack.User, error) {
+	protectedUsers, _, err := getUsersInfo(config, d.Get("protected_members").(*schema.Set), onUnresolvableMemberWarnAndSkip)
+	if err != nil {
+		return nil, fmt.Errorf("could not get protected user information: %s", err)
 	}
-	return intruders, nil
+	return protectedUsers, nil
+}
+
+// Returns true if u must never be kicked out of a conversation, the token owner always is
+func isProtectedUser(config *Config, protectedUsers []*slack.User, u *slack.User) bool {
+	switch {
+	case u.ID == config.UserID:
+		return true
+	case containsUser(protectedUsers, u):
+		return true
+	case config.NeverKickBots && u.IsBot:
+		return true
+	case config.NeverKickAdmins && (u.IsAdmin || u.IsOwner || u.IsPrimaryOwner):
+		return true
+	}
+	return false
+}
+
+// Returns users without the protected ones
+func withoutProtectedUsers(config *Config, protectedUsers []*slack.User, users []*slack.User) []*slack.User {
+	result := make([]*slack.User, 0, len(users))
+	for _, u := range users {
+		if isProtectedUser(config, protectedUsers, u) {
+			log.Printf("[INFO] Not kicking the protected user %s (%s)", u.Name, u.ID)
+			continue
+		}
+		result = append(result, u)
+	}
+	return result
+}
+
+// Returns the members of the conversation that are neither managed nor protected
+func getIntruders(config *Config, conversationMembers []string, managedUsers []*slack.User, protectedUsers []*slack.User) ([]*slack.User, error) {
+	intruders := make([]*slack.User, 0)
+	for _, cmId := range conversationMembers {
+		if cmId == config.UserID || containsUser(managedUsers, &slack.User{ID: cmId}) || containsUser(protectedUsers, &slack.User{ID: cmId}) {
+			continue
+		}
+		intruder, err := config.Users.GetUserInfo(cmId)
+		if err != nil {
+			return nil, slackErrorf(fmt.Sprintf("could not get intruder user %s information", cmId), err)
+		}
+		intruders = append(intruders, intruder)
+	}
+	return withoutProtectedUsers(config, protectedUsers, intruders), nil
+}
+
+func getUsersToKickAuthoritative(config *Config, c *slack.Channel, managedUsers []*slack.User, protectedUsers []*slack.User) ([]*slack.User, error) {
+	conversationMembers, err := getConversationMembers(config.Client, c.ID)
+	if err != nil {
+		return nil, slackErrorf(fmt.Sprintf("(kickUsers) could not get the list of users in the conversation %s!", c.Name), err)
+	}
+	return getIntruders(config, conversationMembers, managedUsers, protectedUsers)
 }
 
 // Kicks users out of a given conversation
 func kickUsers(api *slack.Client, c *slack.Channel, users []*slack.User) error {
 	for _, u := range users {
 		err := api.KickUserFromConversation(c.ID, u.ID)
-		if err != nil {
-			switch err.Error() {
-			case "cant_kick_self":
-				if _, err = api.LeaveConversation(c.ID); err != nil {
-					return fmt.Errorf("could not self-leave the conversation: %s", err)
-				}
-			case "not_in_channel":
-				continue
-			case "user_not_found":
-				continue
-			case "channel_not_found":
-				continue
-			//TODO: Should actually break or be handled better
-			//case "method_not_supported_for_channel_type":
-			//	continue
-			//TODO: Should check that general is not the managed conversation way before
-			case "cant_kick_from_general":
-				continue
-			default:
-				return fmt.Errorf("could not kick user %s out of conversation: %s", u.Name, err)
-			}
+		switch {
+		case err == nil:
+			continue
+		//TODO: Should actually break or be handled better on "method_not_supported_for_channel_type"
+		//TODO: Should check that general is not the managed conversation way before "cant_kick_from_general"
+		// The token owner is protected, leaving the conversation would lose access to it
+		case isSlackError(err, "cant_kick_self", "not_in_channel", "user_not_found", "channel_not_found", "cant_kick_from_general"):
+			continue
+		default:
+			return slackErrorf(fmt.Sprintf("kicking user %s out of conversation %s", u.Name, c.ID), err)
 		}
 	}
 	return nil
 }
 
 // Invite users within a given conversation
-func inviteUsers(api *slack.Client, c *slack.Channel, managedUsers []*slack.User) error {
-	//var usersIdsToInvite []string
-	//conversationMembers, _, err := api.GetUsersInConversation(&slack.GetUsersInConversationParameters{
-	//	ChannelID: c.ID,
-	//	Cursor:    "", // TODO: implement a cursor for paginated API reads
-	//	Limit:     0,
-	//})
-	//if err != nil {
-	//	return fmt.Errorf("could not get the list of users in the conversation %s! %s", c.Name, err)
-	//}
+func inviteUsers(config *Config, c *slack.Channel, managedUsers []*slack.User) error {
+	api := config.Client
+	conversationMembers, err := getConversationMembers(api, c.ID)
+	if err != nil {
+		return slackErrorf(fmt.Sprintf("could not get the list of users in the conversation %s!", c.Name), err)
+	}
 	// Reduces the number of API calls by figuring out which users are already invited
-	//for _, mu := range managedUsers {
-	//	for i, cm := range conversationMembers {
-	//		if mu.ID == cm {
-	//			break
-	//		}
-	//		if i == len(conversationMembers)-1 {
-	//			usersIdsToInvite = append(usersIdsToInvite, mu.ID)
-	//		}
-	//	}
-	//}
-	// Invite all relevant users in a single API call
-	//_, err = api.InviteUsersToConversation(c.ID, usersIdsToInvite...)
-	//if err != nil {
-	// Retry one by one to pinpoint the problematic userID
-	for _, u := range managedUsers {
-		_, err := api.InviteUsersToConversation(c.ID, u.ID)
-		if err != nil {
-			switch {
-			case err.Error() == "cant_invite_self":
-				if _, _, _, err = api.JoinConversation(c.ID); err != nil {
-					return fmt.Errorf("could not self-join conversation: %s", err)
-				}
-				continue
-			case err.Error() == "already_in_channel":
-				continue
-			default:
-				return fmt.Errorf("could not invite userID %s to conversation: %s", u.ID, err)
+	usersIdsToInvite := make([]string, 0)
+	for _, mu := range managedUsers {
+		if containsString(conversationMembers, mu.ID) || containsString(usersIdsToInvite, mu.ID) {
+			continue
+		}
+		// Deactivated accounts can't be invited, they would fail the whole batch
+		if mu.Deleted {
+			log.Printf("[WARN] Not inviting the deactivated user %s (%s) to conversation %s", mu.Name, mu.ID, c.ID)
+			continue
+		}
+		// The token owner can't invite itself either (cant_invite_self), it joins instead
+		if mu.ID == config.UserID {
+			if _, _, _, err := api.JoinConversation(c.ID); err != nil {
+				return slackErrorf(fmt.Sprintf("joining conversation %s", c.ID), err)
 			}
+			conversationMembers = append(conversationMembers, mu.ID)
+			continue
+		}
+		usersIdsToInvite = append(usersIdsToInvite, mu.ID)
+	}
+	for start := 0; start < len(usersIdsToInvite); start += inviteBatchSize {
+		end := start + inviteBatchSize
+		if end > len(usersIdsToInvite) {
+			end = len(usersIdsToInvite)
+		}
+		if err := inviteUsersBatch(api, c, usersIdsToInvite[start:end]); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// Invites a batch of users in a single API call, retrying one by one to pinpoint the problematic userID if it fails
+func inviteUsersBatch(api *slack.Client, c *slack.Channel, usersIds []string) error {
+	_, err := api.InviteUsersToConversation(c.ID, usersIds...)
+	if err == nil {
+		return nil
+	}
+	// Inviting users one by one would fail the same way
+	if isRetryableSlackError(err) {
+		return slackErrorf(fmt.Sprintf("inviting %d users to conversation %s", len(usersIds), c.ID), err)
+	}
+	log.Printf("[DEBUG] Could not invite %d users to conversation %s at once, inviting them one by one: %s", len(usersIds), c.ID, err)
+	// Every user is tried, so that a single problematic user does not hide the others
+	errs := make([]string, 0)
+	for _, id := range usersIds {
+		_, err := api.InviteUsersToConversation(c.ID, id)
+		switch {
+		case err == nil, isSlackError(err, "already_in_channel"):
+			continue
+		case isSlackError(err, "cant_invite_self"):
+			if _, _, _, err = api.JoinConversation(c.ID); err != nil {
+				errs = append(errs, slackErrorf(fmt.Sprintf("joining conversation %s", c.ID), err).Error())
+			}
+		default:
+			errs = append(errs, slackErrorf(fmt.Sprintf("inviting user %s to conversation %s", id, c.ID), err).Error())
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s", errs[0])
+	}
+	return fmt.Errorf("%d users could not be invited to conversation %s:\n  - %s", len(errs), c.ID, strings.Join(errs, "\n  - "))
+}
+
 func resourceConversationMembersRead(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	config := meta.(*Config)
+	api := config.Client
 	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
 	if err != nil {
-		d.SetId("")
-		return nil
+		if isSlackError(err, "channel_not_found") {
+			log.Printf("[WARN] Slack conversation %s not found, removing its members from state", d.Get("conversation_id"))
+			d.SetId("")
+			return nil
+		}
+		return slackErrorf("slack_conversation_members read", err)
 	}
- 
-	conversationMembers, _, err := api.GetUsersInConversation(&slack.GetUsersInConversationParameters{
-		ChannelID: c.ID,
-		Cursor:    "", // TODO: implement a cursor for paginated API reads
-		Limit:     0,
-	})
+
+	conversationMembers, err := getConversationMembers(api, c.ID)
 	if err != nil {
-		return fmt.Errorf("resourceConversationMembersRead: could not get the list of users in the conversation %s! %s", c.Name, err)
+		return slackErrorf("slack_conversation_members read", err)
 	}
 
-	// Synchronize terraform state's members attribute relative to present conversation members
-	members := d.Get("members").([]interface{})
+	// Synchronize terraform state's members attribute relative to present conversation members:
+	// an expression is only kept if all the users it resolves to are present
+	members := d.Get("members").(*schema.Set).List()
+	onUnresolvable := d.Get("on_unresolvable_member").(string)
 	membersUsers := make([]*slack.User, 0)
 	presentMembers := make([]string, 0)
-	presentMembersIds := make([]string, 0)
+	unresolvedMembers := make([]string, 0)
+	resolvedMembers := make(map[string]interface{})
 
+	expressions := make([]string, 0, len(members))
 	for _, m := range members {
-		mi, _ := getUserInfo(api, m.(string))
-		membersUsers = append(membersUsers, mi)
-		for _, cmId := range conversationMembers {
-			if mi.ID == cmId {
-				presentMembers = append(presentMembers, m.(string))
-				presentMembersIds = append(presentMembersIds, mi.ID)
-				break
+		expressions = append(expressions, m.(string))
+	}
+	errs := make([]string, 0)
+	for _, r := range resolveMemberExpressions(config, expressions) {
+		if r.err != nil {
+			if !isUnresolvableMember(r.err) {
+				errs = append(errs, slackErrorf("member "+r.expression, r.err).Error())
+				continue
 			}
+			log.Printf("[WARN] The %s", r.err)
+			unresolvedMembers = append(unresolvedMembers, r.expression)
+			// With "error", an unresolvable expression drifts so that the next apply fails on it,
+			// with "remove" it only drifts while the users it previously matched are still members
+			if onUnresolvable != onUnresolvableMemberError {
+				presentMembers = append(presentMembers, r.expression)
+			}
+			continue
 		}
-	}
-	
-	sort.Strings(presentMembersIds)
-	
-	if d.Get("authoritative").(bool) {
-		intruders := make([]*slack.User, 0)
-		for _, cmId := range conversationMembers {
-			for i, m := range membersUsers {
-				if m.ID == cmId {
-					break
-				}
-				if i == len(membersUsers)-1 {
-					intruder, err := api.GetUserInfo(cmId)
-					if err != nil {
-						return fmt.Errorf("could not get user %s information: %s", cmId, err)
-					}
-					intruders = append(intruders, intruder)
+		present := true
+		for _, u := range r.users {
+			membersUsers = append(membersUsers, u)
+			if !containsString(conversationMembers, u.ID) {
+				// Deactivated accounts are never invited
+				if !u.Deleted {
+					present = false
 				}
+				continue
 			}
+			if _, ok := resolvedMembers[u.ID]; !ok {
+				resolvedMembers[u.ID] = r.expression
+			}
+		}
+		if present {
+			presentMembers = append(presentMembers, r.expression)
+		}
+	}
+	if err := memberResolutionErrors(errs); err != nil {
+		return fmt.Errorf("resourceConversationMembersRead: %s", err)
+	}
+
+	// Users previously resolved from an expression but not anymore (i.e. removed from a user group)
+	// that are still present make the expression drift, until Update kicks them out
+	for id, m := range d.Get("resolved_members").(map[string]interface{}) {
+		if containsUser(membersUsers, &slack.User{ID: id}) || !containsString(conversationMembers, id) {
+			continue
+		}
+		resolvedMembers[id] = m
+		// The users of a skipped expression are left alone
+		if onUnresolvable == onUnresolvableMemberWarnAndSkip && containsString(unresolvedMembers, m.(string)) {
+			continue
+		}
+		log.Printf("[DEBUG] User %s is not resolved from %s anymore but is still a member of %s", id, m, c.ID)
+		presentMembers = removeString(presentMembers, m.(string))
+	}
+
+	presentMembersIds := make([]string, 0, len(resolvedMembers))
+	for id := range resolvedMembers {
+		presentMembersIds = append(presentMembersIds, id)
+	}
+
+	if d.Get("authoritative").(bool) {
+		protectedUsers, err := getProtectedUsers(config, d)
+		if err != nil {
+			return err
+		}
+		for id := range resolvedMembers {
+			membersUsers = append(membersUsers, &slack.User{ID: id})
+		}
+		intruders, err := getIntruders(config, conversationMembers, membersUsers, protectedUsers)
+		if err != nil {
+			return err
 		}
 		for _, intruder := range intruders {
 			b := strings.Builder{}
-			b.WriteString("id:")
+			b.WriteString(memberExpressionIDPrefix)
 			b.WriteString(intruder.ID)
 			presentMembers = append(presentMembers, b.String())
 			presentMembersIds = append(presentMembersIds, intruder.ID)
@@ -242,135 +621,346 @@ func resourceConversationMembersRead(d *schema.ResourceData, meta interface{}) e
 	if err = d.Set("members_ids", presentMembersIds); err != nil {
 		return err
 	}
+	if err = d.Set("resolved_members", resolvedMembers); err != nil {
+		return err
+	}
+	if err = d.Set("unresolved_members", unresolvedMembers); err != nil {
+		return err
+	}
+	// Only known at plan time, once refreshed there is nothing left to remove
+	if err = d.Set("pending_removals", map[string]interface{}{}); err != nil {
+		return err
+	}
 	return nil
 }
 
 func resourceConversationMembersCreate(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
+	config := meta.(*Config)
+	api := config.Client
 	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
 	if err != nil {
-		return fmt.Errorf("could not get conversation details: %s", err)
+		return slackErrorf("slack_conversation_members create", err)
 	}
 
-	members := d.Get("members").([]interface{})
-	managedUsers := make([]*slack.User, len(members))
-	for i, m := range members {
-		managedUsers[i], err = getUserInfo(api, m.(string))
-		if err != nil {
-			return err
-		}
-	}
-	err = inviteUsers(api, c, managedUsers)
+	managedUsers, usersToKick, err := getMembershipChanges(config, d, c)
 	if err != nil {
 		return err
 	}
-	if d.Get("authoritative").(bool) {
-		usersToKick, err := getUsersToKickAuthoritative(api, c, managedUsers)
-		if err != nil {
-			return err
-		}
-		if err = kickUsers(api, c, usersToKick); err != nil {
-			return err
-		}
+	if err = checkMaxRemovals(d, config, c, usersToKick); err != nil {
+		return err
 	}
+	// Set before changing anything, so that a partly failed create is saved (tainted) and cleaned up on destroy
 	b := strings.Builder{}
 	b.WriteString(c.ID)
-	b.WriteString("-members")
+	b.WriteString(conversationMembersIDSuffix)
 	d.SetId(b.String())
-	return resourceConversationMembersRead(d, meta)
+	err = inviteUsers(config, c, managedUsers)
+	if err != nil {
+		return err
+	}
+	if err = kickUsers(api, c, usersToKick); err != nil {
+		return err
+	}
+	if err = resourceConversationMembersRead(d, meta); err != nil {
+		return err
+	}
+	// Same as planned, so that the applied state matches the plan
+	return d.Set("pending_removals", getPendingRemovals(usersToKick))
 }
 
 func resourceConversationMembersUpdate(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
-	usersToKick := make([]*slack.User, 0)
+	config := meta.(*Config)
+	api := config.Client
 	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
 	if err != nil {
-		return fmt.Errorf("could not get conversation information: %s", err)
+		return slackErrorf("slack_conversation_members update", err)
 	}
-	
-	members := d.Get("members").([]interface{})
-	managedUsers := make([]*slack.User, len(members))
-	for i, m := range members {
-		managedUsers[i], err = getUserInfo(api, m.(string))
+
+	managedUsers, usersToKick, err := getMembershipChanges(config, d, c)
+	if err != nil {
+		return err
+	}
+	if err = checkMaxRemovals(d, config, c, usersToKick); err != nil {
+		return err
+	}
+	if len(usersToKick) > 0 {
+		err = kickUsers(api, c, usersToKick)
 		if err != nil {
 			return err
 		}
 	}
+	if err = inviteUsers(config, c, managedUsers); err != nil {
+		return err
+	}
+	if err = resourceConversationMembersRead(d, meta); err != nil {
+		return err
+	}
+	// Same as planned, so that the applied state matches the plan
+	return d.Set("pending_removals", getPendingRemovals(usersToKick))
+}
+
+// Shows the members the next apply kicks out of the conversation in the plan
+func resourceConversationMembersCustomizeDiff(d *schema.ResourceDiff, meta interface{}) error {
+	for _, k := range []string{"conversation_id", "members", "protected_members"} {
+		if !d.NewValueKnown(k) {
+			return d.SetNewComputed("pending_removals")
+		}
+	}
+
+	// Read has just refreshed the members, intruders included: nobody is kicked out unless the configuration changed
+	if d.Id() != "" {
+		changed := false
+		for _, k := range []string{"conversation_id", "members", "protected_members", "authoritative", "on_unresolvable_member"} {
+			changed = changed || d.HasChange(k)
+		}
+		if !changed {
+			return nil
+		}
+	}
+
+	config := meta.(*Config)
+	// Listing the members only needs the conversation ID, saving a conversations.info call on every plan
+	c := &slack.Channel{}
+	c.ID = d.Get("conversation_id").(string)
+	_, usersToKick, err := getMembershipChanges(config, d, c)
+	if err != nil {
+		log.Printf("[WARN] Could not compute the membership changes of conversation %s, pending removals are only known at apply time: %s", c.ID, err)
+		return d.SetNewComputed("pending_removals")
+	}
+
+	pendingRemovals := getPendingRemovals(usersToKick)
+	if len(pendingRemovals) == 0 && len(d.Get("pending_removals").(map[string]interface{})) == 0 {
+		return nil
+	}
+	return d.SetNew("pending_removals", pendingRemovals)
+}
+
+// Returns the pending_removals of usersToKick
+func getPendingRemovals(usersToKick []*slack.User) map[string]interface{} {
+	pendingRemovals := make(map[string]interface{}, len(usersToKick))
+	for _, u := range usersToKick {
+		pendingRemovals[u.ID] = u.Name
+	}
+	return pendingRemovals
+}
+
+// conversationMembersData is implemented by both *schema.ResourceData and *schema.ResourceDiff,
+// so that the membership changes are computed the same way at plan time and at apply time
+type conversationMembersData interface {
+	Id() string
+	Get(key string) interface{}
+	GetChange(key string) (interface{}, interface{})
+}
+
+// Returns the users to invite to and the users to kick out of the conversation
+func getMembershipChanges(config *Config, d conversationMembersData, c *slack.Channel) (managedUsers []*slack.User, usersToKick []*slack.User, err error) {
+	onUnresolvable := d.Get("on_unresolvable_member").(string)
+	managedUsers, unresolvedMembers, err := getUsersInfo(config, d.Get("members").(*schema.Set), onUnresolvable)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The users previously resolved from a skipped expression are left alone
+	keptUsers := append(make([]*slack.User, 0), managedUsers...)
+	if onUnresolvable == onUnresolvableMemberWarnAndSkip {
+		o, _ := d.GetChange("resolved_members")
+		for id, m := range o.(map[string]interface{}) {
+			if containsString(unresolvedMembers, m.(string)) {
+				keptUsers = append(keptUsers, &slack.User{ID: id})
+			}
+		}
+	}
+
+	protectedUsers, err := getProtectedUsers(config, d)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	usersToKick = make([]*slack.User, 0)
 	if !d.Get("authoritative").(bool) {
 		// Kick previously managed users ONLY
 		// (non-authoritative for a given conversation)
-		oldMembers, newMembers := d.GetChange("members")
-		for _, o := range oldMembers.([]interface{}) {
-			for i, n := range newMembers.([]interface{}) {
-				if reflect.DeepEqual(o, n) {
-					break
-				}
-				if i == len(newMembers.([]interface{}))-1 {
-					u, err := getUserInfo(api, o.(string))
-					if err != nil {
-						return fmt.Errorf("could not get old user %s information: %s", o.(string), err)
-					}
-					usersToKick = append(usersToKick, u)
-				}
+		previouslyManagedIds, err := getPreviouslyManagedIds(config, d)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range previouslyManagedIds {
+			// The same user may still be managed through another expression (i.e. "email:" replaced by "id:")
+			if containsUser(keptUsers, &slack.User{ID: id}) {
+				continue
+			}
+			u, err := config.Users.GetUserInfo(id)
+			if err != nil {
+				return nil, nil, slackErrorf(fmt.Sprintf("could not get old user %s information", id), err)
 			}
+			usersToKick = append(usersToKick, u)
 		}
+		usersToKick = withoutProtectedUsers(config, protectedUsers, usersToKick)
 	} else {
 		// Kick all users not managed by terraform
 		// (authoritative for a given conversation)
-		if usersToKick, err = getUsersToKickAuthoritative(api, c, managedUsers); err != nil {
-			return err
+		if usersToKick, err = getUsersToKickAuthoritative(config, c, keptUsers, protectedUsers); err != nil {
+			return nil, nil, err
 		}
 	}
-	if len(usersToKick) > 0 {
-		err = kickUsers(api, c, usersToKick)
-		if err != nil {
-			return err
-		}
+	return managedUsers, usersToKick, nil
+}
+
+// Fails if more users than allowed by max_removals (or the provider's max_removals) are about to be kicked out
+func checkMaxRemovals(d *schema.ResourceData, config *Config, c *slack.Channel, usersToKick []*slack.User) error {
+	maxRemovals := config.MaxRemovals
+	if v, ok := d.GetOkExists("max_removals"); ok {
+		maxRemovals = v.(int)
 	}
-	if err = inviteUsers(api, c, managedUsers); err != nil {
-		return err
+	if maxRemovals < 0 || len(usersToKick) <= maxRemovals {
+		return nil
 	}
-	return resourceConversationMembersRead(d, meta)
+	users := make([]string, len(usersToKick))
+	for i, u := range usersToKick {
+		users[i] = fmt.Sprintf("%s (%s)", u.Name, u.ID)
+	}
+	return fmt.Errorf("refusing to kick %d users out of conversation %s, more than max_removals (%d): %s", len(usersToKick), c.ID, maxRemovals, strings.Join(users, ", "))
+}
+
+// Returns the IDs of the users managed before the update, as recorded by resolved_members
+func getPreviouslyManagedIds(config *Config, d conversationMembersData) ([]string, error) {
+	o, _ := d.GetChange("resolved_members")
+	ids := make([]string, 0)
+	for id := range o.(map[string]interface{}) {
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		return ids, nil
+	}
+
+	// States written before resolved_members existed only know about the removed expressions
+	oldMembers, newMembers := d.GetChange("members")
+	removedUsers, _, err := getUsersInfo(config, oldMembers.(*schema.Set).Difference(newMembers.(*schema.Set)), onUnresolvableMemberWarnAndSkip)
+	if err != nil {
+		return nil, fmt.Errorf("could not get old user information: %s", err)
+	}
+	for _, u := range removedUsers {
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
 }
 
 func resourceConversationMembersDelete(d *schema.ResourceData, meta interface{}) error {
-	api := slack.New(meta.(*Config).APIToken)
-	usersToKick := make([]*slack.User, 0)
+	config := meta.(*Config)
+	api := config.Client
+
+	action := d.Get("action_on_destroy").(string)
+	if action == membersActionOnDestroyNone {
+		log.Printf("[INFO] action_on_destroy is %q, leaving the members of Slack conversation %s untouched", action, d.Get("conversation_id"))
+		return nil
+	}
 
 	c, err := api.GetConversationInfo(d.Get("conversation_id").(string), false)
 	if err != nil {
-		return fmt.Errorf("could not get conversation information: %s", err)
+		return slackErrorf("slack_conversation_members delete", err)
+	}
+	usersToKick, err := getUsersToKickOnDestroy(config, d, c, action)
+	if err != nil {
+		return err
+	}
+	// Destroying the resource is no reason to kick out more users than allowed
+	if err = checkMaxRemovals(d, config, c, usersToKick); err != nil {
+		return err
 	}
+	return kickUsers(api, c, usersToKick)
+}
 
-	// Kick all users in case of simultaneous state change + resource destruction
-	members := make(map[string]string, 0)
-	membersKeys := make([]string, 0)
+// Returns the users action_on_destroy kicks out of the conversation
+func getUsersToKickOnDestroy(config *Config, d *schema.ResourceData, c *slack.Channel, action string) ([]*slack.User, error) {
+	protectedUsers, err := getProtectedUsers(config, d)
+	if err != nil {
+		return nil, err
+	}
 
+	if action == membersActionOnDestroyKickAllButProtected {
+		return getUsersToKickAuthoritative(config, c, nil, protectedUsers)
+	}
+
+	// kick_managed, also the behavior of the states written before action_on_destroy existed
+	// Kick all users in case of simultaneous state change + resource destruction
 	oldUsers, newUsers := d.GetChange("members")
-	for _, o := range oldUsers.([]interface{}) {
-		members[o.(string)] = ""
-		membersKeys = append(membersKeys, o.(string))
+	members := oldUsers.(*schema.Set).Union(newUsers.(*schema.Set))
+	usersToKick, _, err := getUsersInfo(config, members, onUnresolvableMemberWarnAndSkip)
+	if err != nil {
+		return nil, err
 	}
-	for _, n := range newUsers.([]interface{}) {
-		if _, ok := members[n.(string)]; !ok {
-			members[n.(string)] = ""
-			membersKeys = append(membersKeys, n.(string))
+	// Users resolved from a user group they have since left are still managed until kicked out
+	for id := range d.Get("resolved_members").(map[string]interface{}) {
+		if containsUser(usersToKick, &slack.User{ID: id}) {
+			continue
 		}
-	}
-
-	for _, m := range membersKeys {
-		u, err := getUserInfo(api, m)
+		u, err := config.Users.GetUserInfo(id)
 		if err != nil {
-			switch err.Error() {
-			case "user_not_found":
+			if isSlackError(err, "user_not_found") {
 				continue
-			default:
-				return err
 			}
+			return nil, slackErrorf(fmt.Sprintf("could not get user %s information", id), err)
 		}
 		usersToKick = append(usersToKick, u)
 	}
+	return withoutProtectedUsers(config, protectedUsers, usersToKick), nil
+}
 
-	return kickUsers(api, c, usersToKick)
+// Imports the current members of a conversation, as "id:" expressions or as "email:" expressions
+// with a ":email" suffix (users without an email, i.e. bots, are still imported by ID)
+func resourceConversationMembersImport(d *schema.ResourceData, meta interface{}) ([]*schema.ResourceData, error) {
+	config := meta.(*Config)
+	api := config.Client
+
+	parts := strings.SplitN(d.Id(), ":", 2)
+	conversationID := strings.TrimSuffix(parts[0], conversationMembersIDSuffix)
+	format := conversationMembersImportIDFormat
+	if len(parts) == 2 {
+		format = parts[1]
+	}
+	if format != conversationMembersImportIDFormat && format != conversationMembersImportEmailFormat {
+		return nil, fmt.Errorf("unsupported import ID %s, expected <conversationID>, <conversationID>:%s or <conversationID>:%s", d.Id(), conversationMembersImportIDFormat, conversationMembersImportEmailFormat)
+	}
+
+	c, err := api.GetConversationInfo(conversationID, false)
+	if err != nil {
+		return nil, slackErrorf("slack_conversation_members import", err)
+	}
+	conversationMembers, err := getConversationMembers(api, c.ID)
+	if err != nil {
+		return nil, slackErrorf("slack_conversation_members import", err)
+	}
+	if len(conversationMembers) == 0 {
+		return nil, fmt.Errorf("conversation %s has no members to import", c.ID)
+	}
+
+	members := make([]string, 0, len(conversationMembers))
+	resolvedMembers := make(map[string]interface{}, len(conversationMembers))
+	for _, id := range conversationMembers {
+		m := memberExpressionIDPrefix + id
+		if format == conversationMembersImportEmailFormat {
+			u, err := config.Users.GetUserInfo(id)
+			if err != nil {
+				return nil, slackErrorf(fmt.Sprintf("could not get user %s information", id), err)
+			}
+			if u.Profile.Email != "" {
+				m = memberExpressionEmailPrefix + u.Profile.Email
+			} else {
+				log.Printf("[WARN] User %s (%s) has no email, importing it by ID", u.Name, id)
+			}
+		}
+		members = append(members, m)
+		resolvedMembers[id] = m
+	}
+
+	d.SetId(c.ID + conversationMembersIDSuffix)
+	d.Set("conversation_id", c.ID)
+	d.Set("members", members)
+	d.Set("members_ids", conversationMembers)
+	d.Set("resolved_members", resolvedMembers)
+	// The imported members are only managed the way the schema defaults do
+	schemas := resourceConversationMembers().Schema
+	for _, k := range []string{"authoritative", "on_unresolvable_member", "action_on_destroy"} {
+		d.Set(k, schemas[k].Default)
+	}
+	return []*schema.ResourceData{d}, nil
 }
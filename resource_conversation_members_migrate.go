@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+// resourceConversationMembersV0 is the slack_conversation_members schema before members and members_ids became sets
+func resourceConversationMembersV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"conversation_id": &schema.Schema{
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"members": &schema.Schema{
+				Type:     schema.TypeList,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Required: true,
+			},
+			"members_ids": &schema.Schema{
+				Type:     schema.TypeList,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+				Computed: true,
+			},
+			"authoritative": &schema.Schema{
+				Type:     schema.TypeBool,
+				Optional: true,
+			},
+		},
+	}
+}
+
+// Turns the members and members_ids lists into sets, dropping duplicated elements
+func resourceConversationMembersStateUpgradeV0(rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	for _, k := range []string{"members", "members_ids"} {
+		list, ok := rawState[k].([]interface{})
+		if !ok {
+			continue
+		}
+		unique := make([]interface{}, 0, len(list))
+		seen := make(map[interface{}]bool)
+		for _, v := range list {
+			if seen[v] {
+				log.Printf("[INFO] Dropping the duplicated %s element %v of slack_conversation_members %v", k, v, rawState["id"])
+				continue
+			}
+			seen[v] = true
+			unique = append(unique, v)
+		}
+		rawState[k] = unique
+	}
+	return rawState, nil
+}
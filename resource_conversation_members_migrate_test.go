@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResourceConversationMembersStateUpgradeV0(t *testing.T) {
+	cases := map[string]struct {
+		rawState map[string]interface{}
+		expected map[string]interface{}
+	}{
+		"unique members": {
+			rawState: map[string]interface{}{
+				"id":          "C123-members",
+				"members":     []interface{}{"id:U2", "email:alice@example.com"},
+				"members_ids": []interface{}{"U1", "U2"},
+			},
+			expected: map[string]interface{}{
+				"id":          "C123-members",
+				"members":     []interface{}{"id:U2", "email:alice@example.com"},
+				"members_ids": []interface{}{"U1", "U2"},
+			},
+		},
+		"duplicated members": {
+			rawState: map[string]interface{}{
+				"id":          "C123-members",
+				"members":     []interface{}{"id:U1", "id:U2", "id:U1"},
+				"members_ids": []interface{}{"U1", "U1", "U2"},
+			},
+			expected: map[string]interface{}{
+				"id":          "C123-members",
+				"members":     []interface{}{"id:U1", "id:U2"},
+				"members_ids": []interface{}{"U1", "U2"},
+			},
+		},
+		"members_ids missing": {
+			rawState: map[string]interface{}{
+				"id":      "C123-members",
+				"members": []interface{}{"id:U1"},
+			},
+			expected: map[string]interface{}{
+				"id":      "C123-members",
+				"members": []interface{}{"id:U1"},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		actual, err := resourceConversationMembersStateUpgradeV0(tc.rawState, nil)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Fatalf("%s: expected %v, got %v", name, tc.expected, actual)
+		}
+	}
+}
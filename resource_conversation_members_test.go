@@ -0,0 +1,851 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	tfconfig "github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/helper/resource"
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/nlopes/slack"
+)
+
+func TestAccSlackConversationMembers_basic(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	bob := f.AddUser(&fakeUser{Name: "bob", Email: "bob@example.com"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-members"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice, bob),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "email:alice@example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "members_ids.#", "1"),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", fmt.Sprintf("members_ids.%d", schema.HashString(alice)), alice),
+				),
+			},
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "email:alice@example.com", "id:"+bob),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice, bob),
+			},
+			{
+				// Reordering the members is not a change
+				Config:   testAccSlackConversationMembersConfig(channelID, false, "id:"+bob, "email:alice@example.com"),
+				PlanOnly: true,
+			},
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "id:"+bob),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, bob),
+			},
+			{
+				// Replacing a member's expression by an equivalent one keeps the member
+				Config: testAccSlackConversationMembersConfig(channelID, false, "email:bob@example.com"),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, bob),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_authoritative(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	intruder := f.AddUser(&fakeUser{Name: "intruder", Email: "intruder@example.com"})
+	// The token owner is a member of the conversation but not managed: it is never kicked out
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-authoritative", Members: []string{f.OwnerID(), intruder}})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, true, "id:"+alice),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice, f.OwnerID()),
+			},
+		},
+	})
+	if calls := f.Calls("conversations.leave"); calls != 0 {
+		t.Fatalf("expected the token owner to stay, it left %d times", calls)
+	}
+}
+
+func TestAccSlackConversationMembers_inviteSelf(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	// The token owner is not a member yet: it can't invite itself and has to join, it stays on destroy
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-invite-self"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersExact(f, channelID, f.OwnerID()),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "id:"+alice, "email:terraform@example.com"),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice, f.OwnerID()),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_paginated(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+	f.MaxPageSize = 2
+
+	members := make([]string, 0)
+	expressions := make([]string, 0)
+	intruders := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		id := f.AddUser(&fakeUser{Name: fmt.Sprintf("member%d", i)})
+		members = append(members, id)
+		expressions = append(expressions, "id:"+id)
+		intruders = append(intruders, f.AddUser(&fakeUser{Name: fmt.Sprintf("intruder%d", i)}))
+	}
+	// Managed members and intruders are spread over several pages of conversations.members
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-paginated", Members: append(append([]string{}, intruders...), members...)})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, members...),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, true, expressions...),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, members...),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "members_ids.#", "5"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_batchInvite(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	members := make([]string, 0)
+	expressions := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		id := f.AddUser(&fakeUser{Name: fmt.Sprintf("member%d", i)})
+		members = append(members, id)
+		expressions = append(expressions, "id:"+id)
+	}
+	// Already a member, it is not invited again
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-batch", Members: members[:1]})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, members...),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, expressions...),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, members...),
+					func(s *terraform.State) error {
+						if calls := f.Calls("conversations.invite"); calls != 1 {
+							return fmt.Errorf("expected the members to be invited in 1 call, got %d", calls)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_batchInviteSeveralBatches(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+	defer func(size int) { inviteBatchSize = size }(inviteBatchSize)
+	inviteBatchSize = 2
+
+	members := make([]string, 0)
+	expressions := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		id := f.AddUser(&fakeUser{Name: fmt.Sprintf("member%d", i)})
+		members = append(members, id)
+		expressions = append(expressions, "id:"+id)
+	}
+	// The token owner is not a member yet: it joins instead of failing a batch with cant_invite_self
+	expressions = append(expressions, "id:"+f.OwnerID())
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-batches"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, members...),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, expressions...),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, append(members, f.OwnerID())...),
+					func(s *terraform.State) error {
+						if calls := f.Calls("conversations.invite"); calls != 3 {
+							return fmt.Errorf("expected the members to be invited in 3 calls, got %d", calls)
+						}
+						if calls := f.Calls("conversations.join"); calls != 1 {
+							return fmt.Errorf("expected the token owner to join once, got %d", calls)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_batchInviteFailure(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	// A single-channel guest can't be invited to a second channel
+	guest := f.AddUser(&fakeUser{Name: "guest", UltraRestricted: true})
+	otherGuest := f.AddUser(&fakeUser{Name: "other-guest", UltraRestricted: true})
+	f.AddChannel(&fakeChannel{Name: "guests", Members: []string{guest, otherGuest}})
+	bob := f.AddUser(&fakeUser{Name: "bob"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-batch-failure"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice, bob),
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackConversationMembersConfig(channelID, false, "id:"+alice, "id:"+guest, "id:"+bob),
+				ExpectError: regexp.MustCompile(fmt.Sprintf("inviting user %s to conversation %s: ura_max_channels", guest, channelID)),
+			},
+			{
+				// Every guest is reported, the users invited before the failure are still kicked out on destroy
+				PreConfig: func() {
+					if err := testAccCheckSlackConversationMembersExact(f, channelID, alice, bob)(nil); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config:      testAccSlackConversationMembersConfig(channelID, false, "id:"+alice, "id:"+guest, "id:"+otherGuest, "id:"+bob),
+				ExpectError: regexp.MustCompile(fmt.Sprintf("2 users could not be invited to conversation %s", channelID)),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_userGroup(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	bob := f.AddUser(&fakeUser{Name: "bob"})
+	carol := f.AddUser(&fakeUser{Name: "carol", Email: "carol@example.com"})
+	groupID := f.AddUserGroup(&fakeUserGroup{Handle: "eng", Name: "Engineering", Users: []string{alice, bob}})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-usergroup"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice, bob, carol),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "usergroup:eng", "email:carol@example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice, bob, carol),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "resolved_members.%", "3"),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "resolved_members."+alice, "usergroup:eng"),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "resolved_members."+carol, "email:carol@example.com"),
+				),
+			},
+			{
+				// Changes to the group are a drift of the usergroup expression
+				PreConfig: func() {
+					f.UpdateUserGroup(groupID, func(g *fakeUserGroup) { g.Users = []string{alice, carol} })
+				},
+				Config:             testAccSlackConversationMembersConfig(channelID, false, "usergroup:eng", "email:carol@example.com"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// Bob left the group and is kicked out, carol is still managed by her email
+				Config: testAccSlackConversationMembersConfig(channelID, false, "usergroup:"+groupID, "email:carol@example.com"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice, carol),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "resolved_members.%", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_name(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-name"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "name:alice"),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_invalidExpression(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-invalid"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackConversationMembersConfig(channelID, false, "email:alice"),
+				ExpectError: regexp.MustCompile(`"members.0": member expression "email:alice": "alice" is not a valid email address`),
+			},
+		},
+	})
+	if calls := f.Calls("conversations.invite"); calls != 0 {
+		t.Fatalf("expected the plan to fail before any invite, got %d calls", calls)
+	}
+}
+
+func TestAccSlackConversationMembers_unresolvableError(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-unresolvable-error"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccSlackConversationMembersAttributesConfig(channelID, []string{"id:" + alice, "email:ghost@example.com"}, `on_unresolvable_member = "error"`),
+				ExpectError: regexp.MustCompile("member email:ghost@example.com can not be resolved: users_not_found"),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_unresolvableWarnAndSkip(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	deactivated := f.AddUser(&fakeUser{Name: "deactivated", Deleted: true})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-unresolvable-skip"})
+	config := testAccSlackConversationMembersAttributesConfig(channelID, []string{"email:alice@example.com", "email:ghost@example.com", "id:" + deactivated}, `on_unresolvable_member = "warn_and_skip"`)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+		Steps: []resource.TestStep{
+			{
+				// The deactivated user is not invited, without failing the apply
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "unresolved_members.#", "1"),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", fmt.Sprintf("unresolved_members.%d", schema.HashString("email:ghost@example.com")), "email:ghost@example.com"),
+				),
+			},
+			{
+				// Alice's email does not match anymore, she is left in the conversation
+				PreConfig: func() {
+					f.UpdateUser(alice, func(u *fakeUser) { u.Email = "alice@example.org" })
+				},
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "unresolved_members.#", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_unresolvableRemove(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	bob := f.AddUser(&fakeUser{Name: "bob"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-unresolvable-remove"})
+	config := testAccSlackConversationMembersAttributesConfig(channelID, []string{"email:alice@example.com", "id:" + bob}, `on_unresolvable_member = "remove"`)
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice, bob),
+		Steps: []resource.TestStep{
+			{
+				Config: config,
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice, bob),
+			},
+			{
+				// Alice's email does not match anymore, she is kicked out
+				PreConfig: func() {
+					f.UpdateUser(alice, func(u *fakeUser) { u.Email = "alice@example.org" })
+				},
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, bob),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "unresolved_members.#", "1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_protected(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	bob := f.AddUser(&fakeUser{Name: "bob", Email: "bob@example.com"})
+	bot := f.AddUser(&fakeUser{Name: "ci-bot", IsBot: true})
+	admin := f.AddUser(&fakeUser{Name: "admin", IsAdmin: true})
+	intruder := f.AddUser(&fakeUser{Name: "intruder"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-protected", Members: []string{bob, bot, admin, intruder}})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+		Steps: []resource.TestStep{
+			{
+				Config: fmt.Sprintf(`
+provider "slack" {
+  never_kick_bots   = true
+  never_kick_admins = true
+}
+
+resource "slack_conversation_members" "test" {
+  conversation_id   = %q
+  members           = ["id:%s"]
+  protected_members = ["email:bob@example.com"]
+  authoritative     = true
+}
+`, channelID, alice),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice, bob, bot, admin),
+					resource.TestCheckResourceAttr("slack_conversation_members.test", "members.#", "1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_maxRemovals(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	intruders := []string{
+		f.AddUser(&fakeUser{Name: "intruder0"}),
+		f.AddUser(&fakeUser{Name: "intruder1"}),
+		f.AddUser(&fakeUser{Name: "intruder2"}),
+	}
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-max-removals", Members: intruders})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice),
+		Steps: []resource.TestStep{
+			{
+				// Nothing is changed, not even alice's invite
+				Config:      testAccSlackConversationMembersAttributesConfig(channelID, []string{"id:" + alice}, "authoritative = true", "max_removals = 2"),
+				ExpectError: regexp.MustCompile(fmt.Sprintf(`refusing to kick 3 users out of conversation %s, more than max_removals \(2\): intruder0`, channelID)),
+			},
+			{
+				PreConfig: func() {
+					if err := testAccCheckSlackConversationMembersExact(f, channelID, intruders...)(nil); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: testAccSlackConversationMembersAttributesConfig(channelID, []string{"id:" + alice}, "authoritative = true", "max_removals = 3"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckSlackConversationMembersExact(f, channelID, alice),
+					// Checked before the destroy, which kicks alice out as well
+					func(s *terraform.State) error {
+						if calls := f.Calls("conversations.kick"); calls != 3 {
+							return fmt.Errorf("expected 3 kicks, got %d", calls)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_import(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	bot := f.AddUser(&fakeUser{Name: "ci-bot", IsBot: true})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-import", Members: []string{alice, bot}})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersKicked(f, channelID, alice, bot),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersConfig(channelID, false, "id:"+alice, "id:"+bot),
+			},
+			{
+				ResourceName:      "slack_conversation_members.test",
+				ImportState:       true,
+				ImportStateId:     channelID,
+				ImportStateVerify: true,
+			},
+			{
+				// The bot has no email, it is still imported by ID
+				ResourceName:  "slack_conversation_members.test",
+				ImportState:   true,
+				ImportStateId: channelID + ":email",
+				ImportStateCheck: func(s []*terraform.InstanceState) error {
+					if len(s) != 1 {
+						return fmt.Errorf("expected 1 imported state, got %d", len(s))
+					}
+					expected := map[string]string{
+						fmt.Sprintf("members.%d", schema.HashString("email:alice@example.com")): "email:alice@example.com",
+						fmt.Sprintf("members.%d", schema.HashString("id:"+bot)):                 "id:" + bot,
+						"members_ids.#": "2",
+					}
+					for k, v := range expected {
+						if s[0].Attributes[k] != v {
+							return fmt.Errorf("expected %s = %q, got %q", k, v, s[0].Attributes[k])
+						}
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_noneOnDestroy(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-members-none"})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:  func() { testAccPreCheck(t) },
+		Providers: testAccProviders,
+		CheckDestroy: resource.ComposeTestCheckFunc(
+			testAccCheckSlackConversationMembersExact(f, channelID, alice),
+			func(s *terraform.State) error {
+				if calls := f.Calls("conversations.kick"); calls != 0 {
+					return fmt.Errorf("expected no kick, got %d", calls)
+				}
+				return nil
+			},
+		),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersAttributesConfig(channelID, []string{"id:" + alice}, `action_on_destroy = "none"`),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_kickAllButProtectedOnDestroy(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	bob := f.AddUser(&fakeUser{Name: "bob"})
+	other := f.AddUser(&fakeUser{Name: "other"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-members-kick-all", Members: []string{other, f.OwnerID()}})
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersExact(f, channelID, bob, f.OwnerID()),
+		Steps: []resource.TestStep{
+			{
+				Config: testAccSlackConversationMembersAttributesConfig(channelID, []string{"id:" + alice, "id:" + bob}, "protected_members = "+testAccQuotedList("id:"+bob), `action_on_destroy = "kick_all_but_protected"`),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, alice, bob, other, f.OwnerID()),
+			},
+		},
+	})
+}
+
+func TestAccSlackConversationMembers_maxRemovalsOnDestroy(t *testing.T) {
+	f := testAccFakeSlack(t)
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	others := []string{
+		f.AddUser(&fakeUser{Name: "other0"}),
+		f.AddUser(&fakeUser{Name: "other1"}),
+	}
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-acc-max-removals-destroy", Members: others})
+	config := func(maxRemovals int) string {
+		return testAccSlackConversationMembersAttributesConfig(channelID, []string{"id:" + alice},
+			`action_on_destroy = "kick_all_but_protected"`, fmt.Sprintf("max_removals = %d", maxRemovals))
+	}
+
+	resource.Test(t, resource.TestCase{
+		PreCheck:     func() { testAccPreCheck(t) },
+		Providers:    testAccProviders,
+		CheckDestroy: testAccCheckSlackConversationMembersExact(f, channelID),
+		Steps: []resource.TestStep{
+			{
+				Config: config(2),
+				Check:  testAccCheckSlackConversationMembersExact(f, channelID, append(others, alice)...),
+			},
+			{
+				// Nobody is kicked out, not even the ones within the limit
+				Config:      config(2),
+				Destroy:     true,
+				ExpectError: regexp.MustCompile(fmt.Sprintf(`refusing to kick 3 users out of conversation %s, more than max_removals \(2\)`, channelID)),
+			},
+			{
+				PreConfig: func() {
+					if err := testAccCheckSlackConversationMembersExact(f, channelID, append(others, alice)...)(nil); err != nil {
+						t.Fatal(err)
+					}
+				},
+				Config: config(3),
+			},
+		},
+	})
+}
+
+func TestResolveMemberExpressions_parallelism(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	f.Latency = 20 * time.Millisecond
+	expressions := make([]string, 12)
+	for i := range expressions {
+		expressions[i] = memberExpressionIDPrefix + f.AddUser(&fakeUser{Name: fmt.Sprintf("user%d", i)})
+	}
+	config := &Config{APIToken: fakeSlackToken, APIURL: f.URL(), Parallelism: 3}
+	config.loadClients()
+
+	for i, r := range resolveMemberExpressions(config, expressions) {
+		if r.err != nil {
+			t.Fatalf("err: %s", r.err)
+		}
+		if r.expression != expressions[i] || memberExpressionIDPrefix+r.users[0].ID != expressions[i] {
+			t.Fatalf("expected %s at %d, got %s (%s)", expressions[i], i, r.expression, r.users[0].ID)
+		}
+	}
+	if n := f.MaxInFlight(); n < 2 || n > 3 {
+		t.Fatalf("expected 2 to 3 concurrent calls, got %d", n)
+	}
+}
+
+func TestGetUsersInfo_reportsEveryError(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	config := &Config{APIToken: fakeSlackToken, APIURL: f.URL(), Parallelism: 2}
+	config.loadClients()
+
+	members := schema.NewSet(schema.HashString, []interface{}{"id:" + alice, "id:U99999998", "usergroup:nobody"})
+	_, _, err := getUsersInfo(config, members, onUnresolvableMemberError)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	for _, expected := range []string{"2 members could not be resolved", "id:U99999998", "usergroup:nobody"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected %q in %q", expected, err)
+		}
+	}
+
+	users, unresolved, err := getUsersInfo(config, members, onUnresolvableMemberWarnAndSkip)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(users) != 1 || users[0].ID != alice || len(unresolved) != 2 {
+		t.Fatalf("expected %s and 2 unresolved members, got %v and %v", alice, users, unresolved)
+	}
+}
+
+func TestResourceConversationMembersCustomizeDiff_pendingRemovals(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	intruder := f.AddUser(&fakeUser{Name: "intruder"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-pending-removals", Members: []string{alice, intruder}})
+	config := &Config{APIToken: fakeSlackToken, APIURL: f.URL(), Parallelism: 1, MaxRemovals: -1}
+	config.loadClients()
+
+	raw, err := tfconfig.NewRawConfig(map[string]interface{}{
+		"conversation_id": channelID,
+		"members":         []interface{}{"id:" + alice},
+		"authoritative":   true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	diff, err := resourceConversationMembers().Diff(nil, terraform.NewResourceConfig(raw), config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if a := diff.Attributes["pending_removals.%"]; a == nil || a.New != "1" {
+		t.Fatalf("expected 1 pending removal, got %#v", a)
+	}
+	if a := diff.Attributes["pending_removals."+intruder]; a == nil || a.New != "intruder" {
+		t.Fatalf("expected %s to be a pending removal, got %#v", intruder, a)
+	}
+	if calls := f.Calls("conversations.kick"); calls != 0 {
+		t.Fatalf("expected no kick at plan time, got %d", calls)
+	}
+}
+
+func TestCheckMaxRemovals_providerDefault(t *testing.T) {
+	c := &slack.Channel{}
+	c.ID = "C00000001"
+	usersToKick := []*slack.User{{ID: "U00000001", Name: "alice"}, {ID: "U00000002", Name: "bob"}}
+
+	cases := []struct {
+		maxRemovals         interface{}
+		providerMaxRemovals int
+		expectError         bool
+	}{
+		// max_removals unset, the provider's one applies
+		{nil, 1, true},
+		{nil, 2, false},
+		{nil, -1, false},
+		// max_removals set, even to 0, overrides the provider's one
+		{0, -1, true},
+		{2, 1, false},
+		{-1, 0, false},
+	}
+	for _, tc := range cases {
+		raw := map[string]interface{}{"conversation_id": c.ID, "members": []interface{}{}}
+		if tc.maxRemovals != nil {
+			raw["max_removals"] = tc.maxRemovals
+		}
+		d := schema.TestResourceDataRaw(t, resourceConversationMembers().Schema, raw)
+		err := checkMaxRemovals(d, &Config{MaxRemovals: tc.providerMaxRemovals}, c, usersToKick)
+		if (err != nil) != tc.expectError {
+			t.Fatalf("max_removals %v, provider max_removals %d: expected error %t, got %v", tc.maxRemovals, tc.providerMaxRemovals, tc.expectError, err)
+		}
+	}
+}
+
+func TestResourceConversationMembersDelete_none(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	channelID := f.AddChannel(&fakeChannel{Name: "tf-members-none", Members: []string{alice}})
+	config := &Config{APIToken: fakeSlackToken, APIURL: f.URL(), Parallelism: 1, MaxRemovals: -1}
+	config.loadClients()
+
+	r := resourceConversationMembers()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"conversation_id":   channelID,
+		"members":           []interface{}{"id:" + alice},
+		"action_on_destroy": "none",
+	})
+	d.SetId(channelID + conversationMembersIDSuffix)
+	if err := r.Delete(d, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	// The conversation is not even looked up
+	for _, method := range []string{"conversations.info", "conversations.kick"} {
+		if calls := f.Calls(method); calls != 0 {
+			t.Fatalf("expected no %s call, got %d", method, calls)
+		}
+	}
+}
+
+// testAccCheckSlackConversationMembersExact checks that the members of the fake conversation are exactly ids
+func testAccCheckSlackConversationMembersExact(f *fakeSlack, channelID string, ids ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		c := f.Channel(channelID)
+		if c == nil {
+			return fmt.Errorf("channel %s does not exist", channelID)
+		}
+		expected := append([]string{}, ids...)
+		sort.Strings(expected)
+		actual := append([]string{}, c.Members...)
+		sort.Strings(actual)
+		if strings.Join(expected, ",") != strings.Join(actual, ",") {
+			return fmt.Errorf("expected conversation members %v, got %v", expected, actual)
+		}
+		return nil
+	}
+}
+
+// testAccCheckSlackConversationMembersKicked checks that none of ids are left in the fake conversation
+func testAccCheckSlackConversationMembersKicked(f *fakeSlack, channelID string, ids ...string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		c := f.Channel(channelID)
+		if c == nil {
+			return fmt.Errorf("channel %s does not exist", channelID)
+		}
+		for _, id := range ids {
+			if containsString(c.Members, id) {
+				return fmt.Errorf("user %s is still a member of %s", id, c.Name)
+			}
+		}
+		return nil
+	}
+}
+
+func testAccSlackConversationMembersConfig(channelID string, authoritative bool, members ...string) string {
+	return testAccSlackConversationMembersAttributesConfig(channelID, members, fmt.Sprintf("authoritative = %t", authoritative))
+}
+
+// testAccSlackConversationMembersAttributesConfig adds the given attributes (i.e. "max_removals = 2") to the resource
+func testAccSlackConversationMembersAttributesConfig(channelID string, members []string, attributes ...string) string {
+	return fmt.Sprintf(`
+resource "slack_conversation_members" "test" {
+  conversation_id = %q
+  members         = %s
+  %s
+}
+`, channelID, testAccQuotedList(members...), strings.Join(attributes, "\n  "))
+}
+
+// testAccQuotedList returns values as a list of strings (i.e. ["a", "b"])
+func testAccQuotedList(values ...string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// slackError is an error answered by the Slack API (i.e. {"ok": false, "error": "missing_scope"}).
+// rateLimitedTransport decodes it from the response and returns it as the error of the HTTP call, the Slack
+// clients then return it as is, wrapped in the *url.Error of their http.Client: use parseSlackError to get it back.
+type slackError struct {
+	Code     string
+	Needed   string
+	Provided string
+}
+
+// Slack error codes worth retrying, every other one fails the same way until something changes.
+// Only "ratelimited" is retried by rateLimitedTransport, the others may come from a partly applied call.
+var retryableSlackErrorCodes = []string{
+	"ratelimited",
+	"request_timeout",
+	"service_unavailable",
+	"internal_error",
+	"fatal_error",
+}
+
+// Slack error codes telling the token is missing, invalid or not usable anymore
+var authSlackErrorCodes = []string{
+	"not_authed",
+	"invalid_auth",
+	"account_inactive",
+	"token_revoked",
+	"token_expired",
+	"no_permission",
+}
+
+// parseSlackError returns the Slack error behind err, or false if err does not come from a Slack response
+// (i.e. a network error or an error of the provider itself)
+func parseSlackError(err error) (*slackError, bool) {
+	switch e := err.(type) {
+	case *slackError:
+		return e, true
+	case *slackOperationError:
+		return e.Slack, e.Slack != nil
+	case *url.Error:
+		return parseSlackError(e.Err)
+	}
+	return nil, false
+}
+
+func (e *slackError) Error() string {
+	if e.Needed == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s (needed: %s; provided: %s)", e.Code, e.Needed, e.Provided)
+}
+
+// Retryable returns true if the same call may succeed later on, without any change
+func (e *slackError) Retryable() bool {
+	return containsString(retryableSlackErrorCodes, e.Code)
+}
+
+// isSlackError returns true if err is a Slack error with one of the given codes
+func isSlackError(err error, codes ...string) bool {
+	e, ok := parseSlackError(err)
+	return ok && containsString(codes, e.Code)
+}
+
+// isRetryableSlackError returns true if err is a Slack error worth retrying
+func isRetryableSlackError(err error) bool {
+	e, ok := parseSlackError(err)
+	return ok && e.Retryable()
+}
+
+// slackOperationError is the failure of an operation (i.e. "slack_channel create"), its message tells what to do about it
+type slackOperationError struct {
+	Operation string
+	Err       error
+	// nil if Err does not come from a Slack response
+	Slack *slackError
+}
+
+// slackErrorf describes the failure of an operation (i.e. "slack_channel create") with what to do about it
+func slackErrorf(operation string, err error) error {
+	e, _ := parseSlackError(err)
+	return &slackOperationError{Operation: operation, Err: err, Slack: e}
+}
+
+func (e *slackOperationError) Error() string {
+	// An already described failure is only prefixed with the operation
+	if _, described := e.Err.(*slackOperationError); described || e.Slack == nil {
+		return fmt.Sprintf("%s: %s", e.Operation, e.Err)
+	}
+
+	code := e.Slack.Code
+	switch {
+	case code == "missing_scope" && e.Slack.Needed != "":
+		provided := e.Slack.Provided
+		if provided == "" {
+			provided = "no scope"
+		}
+		return fmt.Sprintf("%s requires scope %s; token has %s", e.Operation, e.Slack.Needed, provided)
+	case code == "missing_scope":
+		return fmt.Sprintf("%s requires a scope the token does not have (missing_scope)", e.Operation)
+	case containsString(authSlackErrorCodes, code):
+		return fmt.Sprintf("%s: the Slack token is missing, invalid or revoked (%s), check the provider api_token", e.Operation, code)
+	case code == "restricted_action":
+		return fmt.Sprintf("%s is restricted by the workspace settings (restricted_action), a workspace admin must allow it or a more privileged token be used", e.Operation)
+	case e.Slack.Retryable():
+		return fmt.Sprintf("%s failed with a transient Slack error (%s), retrying later should succeed", e.Operation, code)
+	}
+	return fmt.Sprintf("%s: %s", e.Operation, code)
+}
+
+// Retryable returns true if the operation may succeed later on, without any change
+func (e *slackOperationError) Retryable() bool {
+	return e.Slack != nil && e.Slack.Retryable()
+}
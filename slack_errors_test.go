@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+)
+
+// testSlackCallError returns err as returned by the Slack clients, wrapped by their http.Client
+func testSlackCallError(err error) error {
+	return &url.Error{Op: "Post", URL: "https://slack.com/api/conversations.create", Err: err}
+}
+
+func TestParseSlackError(t *testing.T) {
+	cases := []struct {
+		err      error
+		ok       bool
+		code     string
+		needed   string
+		provided string
+	}{
+		{&slackError{Code: "ratelimited"}, true, "ratelimited", "", ""},
+		{testSlackCallError(&slackError{Code: "channel_not_found"}), true, "channel_not_found", "", ""},
+		{testSlackCallError(&slackError{Code: "missing_scope", Needed: "channels:manage", Provided: "channels:read,users:read"}), true, "missing_scope", "channels:manage", "channels:read,users:read"},
+		{slackErrorf("slack_channel create", testSlackCallError(&slackError{Code: "name_taken"})), true, "name_taken", "", ""},
+		{testSlackCallError(io.EOF), false, "", "", ""},
+		{slackErrorf("slack_channel create", testSlackCallError(io.EOF)), false, "", "", ""},
+		// Only errors answered by Slack are Slack errors, not every error reading like one
+		{errors.New("channel_not_found"), false, "", "", ""},
+		{nil, false, "", "", ""},
+	}
+
+	for _, c := range cases {
+		e, ok := parseSlackError(c.err)
+		if ok != c.ok {
+			t.Fatalf("%v: expected ok %t, got %t", c.err, c.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		if e.Code != c.code || e.Needed != c.needed || e.Provided != c.provided {
+			t.Fatalf("%v: expected %s/%s/%s, got %s/%s/%s", c.err, c.code, c.needed, c.provided, e.Code, e.Needed, e.Provided)
+		}
+	}
+}
+
+func TestSlackError_retryable(t *testing.T) {
+	for code, retryable := range map[string]bool{
+		"ratelimited":         true,
+		"internal_error":      true,
+		"service_unavailable": true,
+		"missing_scope":       false,
+		"channel_not_found":   false,
+		"invalid_auth":        false,
+	} {
+		err := testSlackCallError(&slackError{Code: code})
+		if isRetryableSlackError(err) != retryable {
+			t.Fatalf("expected %s retryable to be %t", code, retryable)
+		}
+		if slackErrorf("slack_channel create", err).(*slackOperationError).Retryable() != retryable {
+			t.Fatalf("expected the operation failing with %s retryable to be %t", code, retryable)
+		}
+	}
+	if slackErrorf("slack_channel create", testSlackCallError(io.EOF)).(*slackOperationError).Retryable() {
+		t.Fatalf("expected an operation failing without Slack error not to be retryable")
+	}
+}
+
+func TestSlackErrorf(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected string
+	}{
+		{&slackError{Code: "missing_scope", Needed: "channels:manage", Provided: "channels:read"}, "slack_channel create requires scope channels:manage; token has channels:read"},
+		{&slackError{Code: "missing_scope", Needed: "channels:manage"}, "slack_channel create requires scope channels:manage; token has no scope"},
+		{&slackError{Code: "missing_scope"}, "slack_channel create requires a scope the token does not have (missing_scope)"},
+		{&slackError{Code: "not_authed"}, "slack_channel create: the Slack token is missing, invalid or revoked (not_authed), check the provider api_token"},
+		{&slackError{Code: "restricted_action"}, "slack_channel create is restricted by the workspace settings (restricted_action), a workspace admin must allow it or a more privileged token be used"},
+		{&slackError{Code: "ratelimited"}, "slack_channel create failed with a transient Slack error (ratelimited), retrying later should succeed"},
+		{&slackError{Code: "name_taken"}, "slack_channel create: name_taken"},
+	}
+
+	for _, c := range cases {
+		if actual := slackErrorf("slack_channel create", testSlackCallError(c.err)).Error(); actual != c.expected {
+			t.Fatalf("%s: expected %q, got %q", c.err, c.expected, actual)
+		}
+	}
+
+	// Other errors are kept as is
+	if err, expected := slackErrorf("slack_channel create", testSlackCallError(io.EOF)), "slack_channel create: "+testSlackCallError(io.EOF).Error(); err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err)
+	}
+
+	// A described failure is only prefixed with the operation calling it
+	err := slackErrorf("slack_channel create", slackErrorf("channel name general is taken", testSlackCallError(&slackError{Code: "missing_scope"})))
+	if expected := "slack_channel create: channel name general is taken requires a scope the token does not have (missing_scope)"; err.Error() != expected {
+		t.Fatalf("expected %q, got %q", expected, err)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitedTransport retries Slack API calls answered with HTTP 429 (or a
+// transient 503, or a "ratelimited" Slack error), honoring Retry-After when
+// Slack provides it and falling back to an exponential backoff with jitter otherwise.
+// Other transient Slack errors (i.e. internal_error) are not retried: the call
+// may have been partly applied and most write methods are not idempotent.
+// Failed calls ({"ok": false, ...}) are returned as a *slackError instead of a response.
+//
+// The transport is shared by every client built in configureProvider: when one
+// call gets rate limited, all the other in-flight calls wait as well instead
+// of hammering the API and being rate limited in turn.
+type rateLimitedTransport struct {
+	transport  http.RoundTripper
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+func newRateLimitedTransport(transport http.RoundTripper, maxRetries int, minBackoff, maxBackoff time.Duration) *rateLimitedTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &rateLimitedTransport{
+		transport:  transport,
+		maxRetries: maxRetries,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+	}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Slack API calls are small form posts: buffer the body so it can be replayed
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.waitUntilUnblocked(req); err != nil {
+			return nil, err
+		}
+
+		r := req
+		if body != nil {
+			r = req.WithContext(req.Context())
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := t.transport.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		reason := resp.Status
+		retry := isRetryableStatus(resp.StatusCode)
+		var slackErr *slackError
+		if !retry {
+			if slackErr, err = decodeSlackError(resp); err != nil {
+				return nil, err
+			}
+			// i.e. {"ok": false, "error": "ratelimited"} answered with HTTP 200
+			if slackErr != nil && slackErr.Code == "ratelimited" {
+				reason, retry = slackErr.Code, true
+			}
+		}
+		if !retry || attempt >= t.maxRetries {
+			if slackErr != nil {
+				resp.Body.Close()
+				return nil, slackErr
+			}
+			return resp, nil
+		}
+
+		wait := t.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = retryAfter + jitter(t.minBackoff)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		log.Printf("[WARN] Slack API %s answered %s, retrying in %s (attempt %d/%d)", req.URL.Path, reason, wait, attempt+1, t.maxRetries)
+		t.block(wait)
+	}
+}
+
+// waitUntilUnblocked sleeps until the shared rate limit window is over, or the request is cancelled
+func (t *rateLimitedTransport) waitUntilUnblocked(req *http.Request) error {
+	t.mu.Lock()
+	wait := time.Until(t.blockedUntil)
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+// block extends the shared rate limit window so that every caller waits at least d
+func (t *rateLimitedTransport) block(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.blockedUntil) {
+		t.blockedUntil = until
+	}
+}
+
+// backoff returns an exponential delay for the given attempt, capped to maxBackoff, with "equal jitter"
+func (t *rateLimitedTransport) backoff(attempt int) time.Duration {
+	d := t.minBackoff
+	for i := 0; i < attempt && d < t.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > t.maxBackoff {
+		d = t.maxBackoff
+	}
+	return d/2 + jitter(d/2)
+}
+
+// decodeSlackError returns the Slack error of a JSON response ({"ok": false, ...}), if any, and leaves the body readable.
+// Only the fields of a failed response are decoded: nlopes/slack would keep nothing but its "error" code.
+func decodeSlackError(resp *http.Response) (*slackError, error) {
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	var r struct {
+		Ok       *bool  `json:"ok"`
+		Error    string `json:"error"`
+		Needed   string `json:"needed"`
+		Provided string `json:"provided"`
+	}
+	if json.Unmarshal(body, &r) != nil || r.Ok == nil || *r.Ok {
+		return nil, nil
+	}
+	return &slackError{Code: r.Error, Needed: r.Needed, Provided: r.Provided}, nil
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter parses a Retry-After header, Slack always sends it as a number of seconds
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(v)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// baseURLTransport sends the requests both Slack libraries build against defaultAPIURL to another base URL.
+// Their base URL is a package level variable, which would be shared by provider aliases with different api_url.
+type baseURLTransport struct {
+	transport http.RoundTripper
+	baseURL   string
+}
+
+func newBaseURLTransport(transport http.RoundTripper, baseURL string) http.RoundTripper {
+	if baseURL == defaultAPIURL {
+		return transport
+	}
+	return &baseURLTransport{transport: transport, baseURL: baseURL}
+}
+
+func (t *baseURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL.String()
+	if !strings.HasPrefix(u, defaultAPIURL) {
+		return t.transport.RoundTrip(req)
+	}
+	target, err := url.Parse(t.baseURL + strings.TrimPrefix(u, defaultAPIURL))
+	if err != nil {
+		return nil, err
+	}
+	r := req.WithContext(req.Context())
+	r.URL = target
+	r.Host = target.Host
+	return t.transport.RoundTrip(r)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedTransport_retriesUntilSuccess(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if string(body) != "channel=C123" {
+			t.Errorf("request body was not replayed, got %q", body)
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRateLimitedTransport(nil, 5, time.Millisecond, 10*time.Millisecond)}
+	resp, err := client.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader("channel=C123"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestRateLimitedTransport_givesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRateLimitedTransport(nil, 2, time.Millisecond, time.Millisecond)}
+	resp, err := client.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429, got %d", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 1 call and 2 retries, got %d calls", calls)
+	}
+}
+
+func TestRateLimitedTransport_retriesRatelimitedOnly(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&calls, 1) < 2 {
+			w.Write([]byte(`{"ok":false,"error":"ratelimited"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":false,"error":"internal_error"}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRateLimitedTransport(nil, 5, time.Millisecond, time.Millisecond)}
+	_, err := client.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(""))
+
+	// internal_error may come from a partly applied write, it is not retried
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+	if !isSlackError(err, "internal_error") {
+		t.Fatalf("expected internal_error, got %v", err)
+	}
+}
+
+func TestRateLimitedTransport_returnsSlackErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"ok":false,"error":"missing_scope","needed":"channels:manage","provided":"channels:read,users:read","warning":"missing_charset"}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRateLimitedTransport(nil, 5, time.Millisecond, time.Millisecond)}
+	resp, err := client.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(""))
+	if err == nil {
+		resp.Body.Close()
+		t.Fatalf("expected an error, got status %d", resp.StatusCode)
+	}
+
+	e, ok := parseSlackError(err)
+	if !ok {
+		t.Fatalf("expected a Slack error, got %v", err)
+	}
+	if e.Code != "missing_scope" || e.Needed != "channels:manage" || e.Provided != "channels:read,users:read" {
+		t.Fatalf("expected missing_scope/channels:manage/channels:read,users:read, got %s/%s/%s", e.Code, e.Needed, e.Provided)
+	}
+}
+
+func TestRateLimitedTransport_passesSuccessesThrough(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true,"channel":{"id":"C123"}}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRateLimitedTransport(nil, 5, time.Millisecond, time.Millisecond)}
+	resp, err := client.Post(server.URL, "application/x-www-form-urlencoded", strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != `{"ok":true,"channel":{"id":"C123"}}` {
+		t.Fatalf("expected the body to be untouched, got %s", body)
+	}
+}
+
+func TestRateLimitedTransport_backoffIsCapped(t *testing.T) {
+	tr := newRateLimitedTransport(nil, 10, time.Second, 4*time.Second)
+	for attempt := 0; attempt < 10; attempt++ {
+		if d := tr.backoff(attempt); d > 4*time.Second {
+			t.Fatalf("attempt %d: backoff %s exceeds max_backoff", attempt, d)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// userDirectory looks Slack users up for every resource and data source of the provider. Unless disabled, it
+// caches the whole workspace, filled from a single paginated users.list and refreshed once older than ttl.
+type userDirectory struct {
+	api     *slack.Client
+	enabled bool
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	loadedAt time.Time
+	users    []slack.User
+	byID     map[string]*slack.User
+	byEmail  map[string]*slack.User
+}
+
+func newUserDirectory(api *slack.Client, enabled bool, ttl time.Duration) *userDirectory {
+	return &userDirectory{
+		api:     api,
+		enabled: enabled,
+		ttl:     ttl,
+	}
+}
+
+// load fills the cache if it is empty or expired, the caller must hold the lock
+func (d *userDirectory) load() error {
+	if d.byID != nil && time.Since(d.loadedAt) < d.ttl {
+		return nil
+	}
+	users, err := getAllUsers(d.api)
+	if err != nil {
+		return err
+	}
+	d.users = users
+	d.byID = make(map[string]*slack.User, len(users))
+	d.byEmail = make(map[string]*slack.User, len(users))
+	for i := range users {
+		d.index(&users[i])
+	}
+	d.loadedAt = time.Now()
+	log.Printf("[DEBUG] Cached %d Slack users for %s", len(users), d.ttl)
+	return nil
+}
+
+// index adds a user to the lookup maps, the caller must hold the lock
+func (d *userDirectory) index(u *slack.User) {
+	d.byID[u.ID] = u
+	// Emails are only listed with the users:read.email scope
+	if u.Profile.Email != "" {
+		d.byEmail[strings.ToLower(u.Profile.Email)] = u
+	}
+}
+
+// cached looks a user up in the cache, filling it first if needed
+func (d *userDirectory) cached(index func() map[string]*slack.User, key string) (*slack.User, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return nil, false, err
+	}
+	u, ok := index()[key]
+	return u, ok, nil
+}
+
+// add caches a user missing from the last users.list
+func (d *userDirectory) add(u *slack.User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.users = append(d.users, *u)
+	d.index(u)
+}
+
+// GetUserInfo returns the user with the given ID, like users.info
+func (d *userDirectory) GetUserInfo(id string) (*slack.User, error) {
+	if !d.enabled {
+		return d.api.GetUserInfo(id)
+	}
+	u, ok, err := d.cached(func() map[string]*slack.User { return d.byID }, id)
+	if err != nil || ok {
+		return u, err
+	}
+	// i.e. a user created after the cache was filled, looked up without holding the lock
+	if u, err = d.api.GetUserInfo(id); err != nil {
+		return nil, err
+	}
+	d.add(u)
+	return u, nil
+}
+
+// GetUserByEmail returns the user with the given email, like users.lookupByEmail
+func (d *userDirectory) GetUserByEmail(email string) (*slack.User, error) {
+	if !d.enabled {
+		return d.api.GetUserByEmail(email)
+	}
+	u, ok, err := d.cached(func() map[string]*slack.User { return d.byEmail }, strings.ToLower(email))
+	if err != nil || ok {
+		return u, err
+	}
+	if u, err = d.api.GetUserByEmail(email); err != nil {
+		return nil, err
+	}
+	d.add(u)
+	return u, nil
+}
+
+// GetUsers returns every user of the workspace, like a paginated users.list
+func (d *userDirectory) GetUsers() ([]slack.User, error) {
+	if !d.enabled {
+		return getAllUsers(d.api)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d.users, nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func testUserDirectory(f *fakeSlack, enabled bool, ttl time.Duration) *userDirectory {
+	config := &Config{APIToken: fakeSlackToken, APIURL: f.URL()}
+	config.loadClients()
+	return newUserDirectory(config.Client, enabled, ttl)
+}
+
+func TestUserDirectory_listsOnce(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	d := testUserDirectory(f, true, time.Hour)
+
+	u, err := d.GetUserInfo(alice)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if u.Name != "alice" {
+		t.Fatalf("expected alice, got %s", u.Name)
+	}
+	if u, err = d.GetUserByEmail("Alice@example.com"); err != nil || u.ID != alice {
+		t.Fatalf("expected %s, got %v (%v)", alice, u, err)
+	}
+	users, err := d.GetUsers()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(users))
+	}
+
+	if n := f.Calls("users.list"); n != 1 {
+		t.Fatalf("expected a single users.list, got %d", n)
+	}
+	if n := f.Calls("users.info") + f.Calls("users.lookupByEmail"); n != 0 {
+		t.Fatalf("expected no single user lookup, got %d", n)
+	}
+}
+
+func TestUserDirectory_concurrent(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	f.MaxPageSize = 2
+	ids := make([]string, 10)
+	for i := range ids {
+		ids[i] = f.AddUser(&fakeUser{Name: "user"})
+	}
+	d := testUserDirectory(f, true, time.Hour)
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if _, err := d.GetUserInfo(id); err != nil {
+				t.Errorf("err: %s", err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	// 11 users, 2 per page
+	if n := f.Calls("users.list"); n != 6 {
+		t.Fatalf("expected the users to be listed once (6 pages), got %d calls", n)
+	}
+}
+
+func TestUserDirectory_expires(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice"})
+	d := testUserDirectory(f, true, time.Minute)
+
+	if _, err := d.GetUserInfo(alice); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f.UpdateUser(alice, func(u *fakeUser) { u.Deleted = true })
+	if u, _ := d.GetUserInfo(alice); u.Deleted {
+		t.Fatalf("expected the cached user before the TTL")
+	}
+
+	d.loadedAt = d.loadedAt.Add(-time.Minute)
+	if u, _ := d.GetUserInfo(alice); !u.Deleted {
+		t.Fatalf("expected the users to be listed again after the TTL")
+	}
+	if n := f.Calls("users.list"); n != 2 {
+		t.Fatalf("expected 2 users.list, got %d", n)
+	}
+}
+
+func TestUserDirectory_missFallsBack(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	d := testUserDirectory(f, true, time.Hour)
+	if _, err := d.GetUsers(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	bob := f.AddUser(&fakeUser{Name: "bob", Email: "bob@example.com"})
+	if u, err := d.GetUserByEmail("bob@example.com"); err != nil || u.ID != bob {
+		t.Fatalf("expected %s, got %v (%v)", bob, u, err)
+	}
+	if u, err := d.GetUserInfo(bob); err != nil || u.Name != "bob" {
+		t.Fatalf("expected bob, got %v (%v)", u, err)
+	}
+	if _, err := d.GetUserInfo("U99999999"); !isSlackError(err, "user_not_found") {
+		t.Fatalf("expected user_not_found, got %v", err)
+	}
+
+	if n := f.Calls("users.list"); n != 1 {
+		t.Fatalf("expected a single users.list, got %d", n)
+	}
+	// bob was cached by the email lookup
+	if n := f.Calls("users.info"); n != 1 {
+		t.Fatalf("expected a single users.info, got %d", n)
+	}
+}
+
+func TestUserDirectory_disabled(t *testing.T) {
+	f := newFakeSlack()
+	defer f.Close()
+
+	alice := f.AddUser(&fakeUser{Name: "alice", Email: "alice@example.com"})
+	d := testUserDirectory(f, false, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.GetUserInfo(alice); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := d.GetUserByEmail("alice@example.com"); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if n := f.Calls("users.list"); n != 0 {
+		t.Fatalf("expected no users.list, got %d", n)
+	}
+	if n := f.Calls("users.info"); n != 2 {
+		t.Fatalf("expected 2 users.info, got %d", n)
+	}
+}